@@ -28,6 +28,7 @@ func main() {
 		"bin/checks/lines.go",
 		"bin/checks/pages.go",
 		"bin/checks/translations.go",
+		"bin/checks/secrets.go",
 	}
 
 	// Normalize to absolute paths where possible and verify existence
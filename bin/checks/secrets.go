@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Rule is a kind of credential recognised by its format
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+	// Generic rules match any credential-like key, so the value must also look random
+	Generic bool
+}
+
+// Finding is a credential found at a file and line
+type Finding struct {
+	Path  string
+	Line  int
+	Rule  string
+	Value string
+}
+
+var (
+	pathsFlag     = flag.String("paths", "custom,.hooks,.plugins/repos", "comma separated directories to scan")
+	rootFlag      = flag.Bool("root", true, "also scan the tracked files at the repository root, except .env")
+	allowlistFile = flag.String("allowlist", ".secrets-allowlist", "file listing deliberately public values, one per line as `value` or `<path glob> value` (dir/** matches a whole directory)")
+	maxSize       = flag.Int64("max-size", 1<<20, "skip files larger than this many bytes")
+	verbose       = flag.Bool("v", true, "verbose output")
+
+	// The capture group "secret" is the value reported and matched against the allowlist
+	rules = []Rule{
+		{Name: "private-key", Pattern: regexp.MustCompile(`(?P<secret>-----BEGIN [A-Z ]*PRIVATE KEY-----)`)},
+		{Name: "aws-access-key", Pattern: regexp.MustCompile(`\b(?P<secret>(?:AKIA|ASIA)[0-9A-Z]{16})\b`)},
+		{Name: "github-token", Pattern: regexp.MustCompile(`\b(?P<secret>(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,}))\b`)},
+		{Name: "gitlab-token", Pattern: regexp.MustCompile(`\b(?P<secret>glpat-[A-Za-z0-9_-]{20,})\b`)},
+		{Name: "stripe-key", Pattern: regexp.MustCompile(`\b(?P<secret>(?:sk|rk)_(?:live|test)_[0-9A-Za-z]{16,})\b`)},
+		{Name: "slack-token", Pattern: regexp.MustCompile(`\b(?P<secret>xox[abposr]-[0-9A-Za-z-]{10,})\b`)},
+		{Name: "google-api-key", Pattern: regexp.MustCompile(`\b(?P<secret>AIza[0-9A-Za-z_-]{35})\b`)},
+		{Name: "jwt", Pattern: regexp.MustCompile(`\b(?P<secret>eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,})`)},
+		// PAYPAL_CLIENT_ID=… or paypal: '…' on one line, nested JSON config is checked by paypalIDs
+		{Name: "paypal-client-id", Pattern: regexp.MustCompile(`(?i)paypal[^\n]{0,80}?["'=:]\s*["']?(?P<secret>A[A-Za-z0-9_-]{79})\b`)},
+		{Name: "url-credentials", Pattern: regexp.MustCompile(`[a-z][a-z0-9+.-]*://[^\s:/@"']+:(?P<secret>[^\s:/@"']{6,})@`)},
+		// "id": "…", apiKey: '…', client_secret = "…"
+		{Name: "credential", Generic: true, Pattern: regexp.MustCompile(`(?i)["']?[A-Za-z0-9_-]*(?:secret|token|passwo?r?d|key|id|auth|credentials?)["']?\s*[:=]\s*["'](?P<secret>[^"'\s]{16,})["']`)},
+		// KEY=… in .env style files
+		{Name: "credential", Generic: true, Pattern: regexp.MustCompile(`(?m)^\s*(?:export\s+)?[A-Z0-9_]*(?:SECRET|TOKEN|PASSWORD|PASSWD|KEY|ID)\s*=\s*(?P<secret>[^\s"'#]{16,})`)},
+	}
+
+	// paypalID is the shape of a PayPal REST client id, found below a "paypal" key in JSON config
+	paypalID = regexp.MustCompile(`^A[A-Za-z0-9_-]{79}$`)
+
+	// randomValue is the shape of a generated id or key: no paths, URLs or sentences
+	randomValue = regexp.MustCompile(`^[A-Za-z0-9_+=/-]+$`)
+
+	skipDirs = map[string]bool{".git": true, "node_modules": true, ".nuxt": true, ".output": true, "dist": true}
+	skipExts = map[string]bool{
+		".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true, ".svg": true, ".ico": true,
+		".woff": true, ".woff2": true, ".ttf": true, ".zip": true, ".lock": true, ".lockb": true,
+	}
+	skipFiles = map[string]bool{"package-lock.json": true, "bun.lock": true, "yarn.lock": true, "pnpm-lock.yaml": true}
+)
+
+// entropy returns the Shannon entropy of s in bits per character
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	var h float64
+	n := float64(len([]rune(s)))
+	for _, c := range counts {
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// looksRandom reports whether a value matched by a generic rule is likely a real credential
+func looksRandom(value string) bool {
+	if strings.Contains(value, "${") || strings.Contains(value, "/") && strings.Contains(value, ".") {
+		return false
+	}
+	if !randomValue.MatchString(value) {
+		return false
+	}
+	hasDigit := strings.ContainsAny(value, "0123456789")
+	hasLetter := strings.IndexFunc(value, func(r rune) bool { return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' }) >= 0
+	return hasDigit && hasLetter && entropy(value) >= 3.5
+}
+
+// mask keeps only the start and end of a secret for output
+func mask(value string) string {
+	if len(value) <= 8 {
+		return strings.Repeat("*", len(value))
+	}
+	return value[:4] + "…" + value[len(value)-4:]
+}
+
+// Allowlist holds deliberately public values, optionally limited to paths
+type Allowlist struct {
+	entries []allowEntry
+}
+
+type allowEntry struct {
+	Glob  string
+	Value string
+}
+
+func readAllowlist(path string) (Allowlist, error) {
+	var list Allowlist
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return list, nil
+	}
+	if err != nil {
+		return list, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		switch len(fields) {
+		case 1:
+			list.entries = append(list.entries, allowEntry{Value: fields[0]})
+		case 2:
+			list.entries = append(list.entries, allowEntry{Glob: fields[0], Value: fields[1]})
+		default:
+			return list, fmt.Errorf("%s: invalid line %q", path, line)
+		}
+	}
+	return list, nil
+}
+
+// allows reports whether value found in path is allowlisted
+func (a Allowlist) allows(path, value string) bool {
+	for _, e := range a.entries {
+		if e.Value != value {
+			continue
+		}
+		if e.Glob == "" {
+			return true
+		}
+		if ok, _ := filepath.Match(e.Glob, filepath.ToSlash(path)); ok {
+			return true
+		}
+		if strings.HasSuffix(e.Glob, "/**") && strings.HasPrefix(filepath.ToSlash(path), strings.TrimSuffix(e.Glob, "**")) {
+			return true
+		}
+	}
+	return false
+}
+
+// paypalIDs collects the PayPal client ids set anywhere below a "paypal" object, e.g.
+// "payment": { "paypal": { "id": "…" } }, which the line based rules cannot tie to PayPal
+func paypalIDs(v interface{}, inPaypal bool, ids map[string]bool) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, item := range value {
+			if id, ok := item.(string); ok && inPaypal && paypalID.MatchString(id) {
+				ids[id] = true
+				continue
+			}
+			paypalIDs(item, inPaypal || strings.EqualFold(k, "paypal"), ids)
+		}
+	case []interface{}:
+		for _, item := range value {
+			paypalIDs(item, inPaypal, ids)
+		}
+	}
+}
+
+// scanFile returns the credentials found in path, one per line and value
+func scanFile(path string) ([]Finding, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return nil, nil // binary
+	}
+
+	ids := make(map[string]bool)
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var decoded interface{}
+		if json.Unmarshal(data, &decoded) == nil {
+			paypalIDs(decoded, false, ids)
+		}
+	}
+
+	var findings []Finding
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		seen := make(map[string]bool)
+		for id := range ids {
+			if strings.Contains(text, `"`+id+`"`) {
+				seen[id] = true
+				findings = append(findings, Finding{Path: path, Line: line, Rule: "paypal-client-id", Value: id})
+			}
+		}
+		for _, rule := range rules {
+			idx := rule.Pattern.SubexpIndex("secret")
+			for _, m := range rule.Pattern.FindAllStringSubmatch(text, -1) {
+				value := m[idx]
+				if seen[value] || (rule.Generic && !looksRandom(value)) {
+					continue
+				}
+				seen[value] = true
+				findings = append(findings, Finding{Path: path, Line: line, Rule: rule.Name, Value: value})
+			}
+		}
+	}
+	return findings, scanner.Err()
+}
+
+// rootFiles returns the tracked files at the repository root, or all of them outside of a git checkout.
+// .env is meant to hold secrets and is skipped
+func rootFiles() []string {
+	var names []string
+	if out, err := exec.Command("git", "ls-files", "--", ":(top)").Output(); err == nil {
+		for _, name := range strings.Split(string(out), "\n") {
+			if name != "" && !strings.Contains(name, "/") {
+				names = append(names, name)
+			}
+		}
+	} else {
+		entries, err := os.ReadDir(".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: cannot list the repository root: %v\n", err)
+			return nil
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				names = append(names, e.Name())
+			}
+		}
+	}
+	var files []string
+	for _, name := range names {
+		if name != ".env" {
+			files = append(files, name)
+		}
+	}
+	return files
+}
+
+func main() {
+	flag.Parse()
+
+	allowlist, err := readAllowlist(*allowlistFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading allowlist: %v\n", err)
+		os.Exit(2)
+	}
+
+	var findings []Finding
+	var scanned, allowed int
+	scan := func(path string, d os.DirEntry) {
+		if skipExts[strings.ToLower(filepath.Ext(path))] || skipFiles[d.Name()] {
+			return
+		}
+		if info, err := d.Info(); err != nil || !info.Mode().IsRegular() || info.Size() > *maxSize {
+			return
+		}
+		scanned++
+		found, err := scanFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: cannot scan %s: %v\n", path, err)
+			return
+		}
+		for _, f := range found {
+			if allowlist.allows(f.Path, f.Value) {
+				allowed++
+				continue
+			}
+			findings = append(findings, f)
+		}
+	}
+
+	if *rootFlag {
+		for _, name := range rootFiles() {
+			if info, err := os.Lstat(name); err == nil {
+				scan(name, fs.FileInfoToDirEntry(info))
+			}
+		}
+	}
+	for _, root := range strings.Split(*pathsFlag, ",") {
+		root = strings.TrimSpace(root)
+		if root == "" {
+			continue
+		}
+		_ = filepath.WalkDir(root, func(path string, d os.DirEntry, walkErr error) error {
+			if walkErr != nil {
+				// missing roots like .hooks before the first build are fine
+				return nil
+			}
+			if d.IsDir() {
+				if skipDirs[d.Name()] && path != root {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			scan(path, d)
+			return nil
+		})
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Path != findings[j].Path {
+			return findings[i].Path < findings[j].Path
+		}
+		return findings[i].Line < findings[j].Line
+	})
+	for _, f := range findings {
+		fmt.Printf("%s:%d: %s %s\n", filepath.ToSlash(f.Path), f.Line, f.Rule, mask(f.Value))
+	}
+
+	if *verbose {
+		fmt.Printf("scanned %d files: %d finding(s), %d allowlisted\n", scanned, len(findings), allowed)
+	}
+	if len(findings) > 0 {
+		fmt.Fprintf(os.Stderr, "move these values to .env (see README, Config per environment) or add deliberately public ones to %s\n", *allowlistFile)
+		os.Exit(1)
+	}
+}
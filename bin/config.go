@@ -0,0 +1,598 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/mail"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Typed model of custom/pocketstore.json. pocketstore.schema.json describes the same shape for
+// editors; keep both in sync.
+//
+// Usage:
+//
+//	go run bin/config.go validate [--env stage] [custom/pocketstore.json]
+//
+// The config is validated as bin/custom.go writes it to storefront/app/pocketstore.json: merged
+// with custom/pocketstore.<env>.json for POCKETSTORE_ENV and with ${ENV_VAR}s replaced.
+
+type Config struct {
+	Schema       string                 `json:"$schema,omitempty"`
+	Domains      Domains                `json:"domains"`
+	Maintenance  *Maintenance           `json:"maintenance,omitempty"`
+	Extension    json.RawMessage        `json:"extension,omitempty"` // false, an extensions URL or vendor/name -> plugin
+	Emails       map[string]Email       `json:"emails,omitempty"`    // e.g. "support"
+	Language     Language               `json:"language"`
+	Currency     Currency               `json:"currency"`
+	Integrations map[string]Integration `json:"integrations,omitempty"` // e.g. "cookiefirst", "pirsch"
+	Payment      Payment                `json:"payment,omitempty"`
+	Layers       []string               `json:"layers,omitempty"` // see bin/layers.go
+	Theme        *Theme                 `json:"theme,omitempty"`  // see bin/theme.go
+}
+
+type Domains struct {
+	Nuxt       string `json:"nuxt"`
+	Pocketbase string `json:"pocketbase"`
+}
+
+type Maintenance struct {
+	Enabled bool   `json:"enabled"`
+	Until   string `json:"until,omitempty"`
+}
+
+type Email struct {
+	Address string `json:"address"`
+	Name    string `json:"name,omitempty"`
+}
+
+type Language struct {
+	Fallback  string   `json:"fallback"`
+	Languages []string `json:"languages"`
+	Locales   []Locale `json:"locales,omitempty"`
+}
+
+type Locale struct {
+	Code string `json:"code"`
+	File string `json:"file"`
+}
+
+type Currency struct {
+	Fallback   string   `json:"fallback"`
+	Currencies []string `json:"currencies"`
+}
+
+type Integration struct {
+	ID string `json:"id"`
+}
+
+type Payment struct {
+	Paypal *Paypal `json:"paypal,omitempty"`
+}
+
+// Theme holds the daisyUI theme tokens bin/theme.go turns into storefront/daisyui.css.
+// Keep in sync with bin/theme.go.
+type Theme struct {
+	Name   string            `json:"name,omitempty"`
+	Radius map[string]string `json:"radius,omitempty"` // selector, field, box
+	Fonts  map[string]string `json:"fonts,omitempty"`  // sans, serif, mono
+	Light  map[string]string `json:"light,omitempty"`  // colour name -> colour
+	Dark   map[string]string `json:"dark,omitempty"`
+}
+
+type Paypal struct {
+	ID       string `json:"id"`
+	Currency string `json:"currency"`
+}
+
+// ExtensionPlugin is one entry of the "extension" map, see PocketstoreConfig in bin/plugins.go
+type ExtensionPlugin struct {
+	Version  string `json:"version"`
+	Name     string `json:"name,omitempty"`
+	Vendor   string `json:"vendor,omitempty"`
+	Prio     int    `json:"prio,omitempty"`
+	Revision string `json:"revision,omitempty"`
+}
+
+// Problem is a single validation finding at a dotted path like "maintenance.until"
+type Problem struct {
+	Path    string
+	Message string
+	Warning bool
+}
+
+func (p Problem) String() string {
+	if p.Path == "" {
+		return p.Message
+	}
+	return p.Path + ": " + p.Message
+}
+
+var (
+	configFile = "custom/pocketstore.json"
+
+	// untilLayouts are the date formats accepted for maintenance.until.
+	// Keep in sync with bin/maintenance.go.
+	untilLayouts = []string{
+		time.RFC3339,
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05",
+		"2006-01-02",
+		"02.01.2006 15:04:05",
+		"02.01.2006 15:04",
+		"02.01.2006",
+	}
+
+	hostPattern     = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*(:[0-9]+)?$`)
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+	rawMessageType  = reflect.TypeOf(json.RawMessage{})
+
+	// envPattern matches ${NAME} and ${NAME:-default}
+	envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+)
+
+// parseUntil parses a maintenance.until date; dates without a zone are local time
+func parseUntil(s string) (time.Time, error) {
+	for _, layout := range untilLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a valid date (use RFC 3339, e.g. 2026-10-12T12:12:12+02:00, or DD.MM.YYYY HH:MM:SS)", s)
+}
+
+// jsonFields returns the JSON keys of struct type t
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// unknownKeys reports keys in data that type t does not declare, suggesting close matches.
+// Values of the wrong type are left to json.Unmarshal.
+func unknownKeys(data json.RawMessage, t reflect.Type, path string) []Problem {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == rawMessageType {
+		return nil
+	}
+
+	var problems []Problem
+	switch t.Kind() {
+	case reflect.Struct:
+		var object map[string]json.RawMessage
+		if json.Unmarshal(data, &object) != nil {
+			return nil
+		}
+		fields := jsonFields(t)
+		for _, key := range sortedKeys(object) {
+			fieldType, ok := fields[key]
+			if !ok {
+				msg := "unknown key"
+				if s := suggest(key, fields); s != "" {
+					msg += fmt.Sprintf(" (did you mean %q?)", s)
+				}
+				problems = append(problems, Problem{Path: join(path, key), Message: msg})
+				continue
+			}
+			problems = append(problems, unknownKeys(object[key], fieldType, join(path, key))...)
+		}
+	case reflect.Map:
+		var object map[string]json.RawMessage
+		if json.Unmarshal(data, &object) != nil {
+			return nil
+		}
+		for _, key := range sortedKeys(object) {
+			problems = append(problems, unknownKeys(object[key], t.Elem(), join(path, key))...)
+		}
+	case reflect.Slice:
+		var items []json.RawMessage
+		if json.Unmarshal(data, &items) != nil {
+			return nil
+		}
+		for i, item := range items {
+			problems = append(problems, unknownKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+	return problems
+}
+
+func sortedKeys(object map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// suggest returns the declared key closest to key, if it is likely a typo
+func suggest(key string, fields map[string]reflect.Type) string {
+	best, bestDist := "", 3
+	for field := range fields {
+		if d := levenshtein(strings.ToLower(key), strings.ToLower(field)); d < bestDist || (d == bestDist && field < best) {
+			best, bestDist = field, d
+		}
+	}
+	if bestDist > 2 {
+		return ""
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// decodeProblem turns a json.Unmarshal error into a Problem with a path or line number
+func decodeProblem(data []byte, err error) Problem {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		line := bytes.Count(data[:syntaxErr.Offset], []byte("\n")) + 1
+		return Problem{Message: fmt.Sprintf("line %d: %v", line, syntaxErr)}
+	case errors.As(err, &typeErr):
+		return Problem{Path: typeErr.Field, Message: fmt.Sprintf("expected %s, got %s", jsonKind(typeErr.Type), typeErr.Value)}
+	}
+	return Problem{Message: err.Error()}
+}
+
+// jsonKind names the JSON value expected for Go type t
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Bool:
+		return "bool"
+	case reflect.String:
+		return "string"
+	case reflect.Ptr:
+		return jsonKind(t.Elem())
+	}
+	return "number"
+}
+
+// contains reports whether list holds s
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// check validates the values of a decoded config
+func (c Config) check() []Problem {
+	var problems []Problem
+	add := func(path, format string, args ...interface{}) {
+		problems = append(problems, Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	warn := func(path, format string, args ...interface{}) {
+		problems = append(problems, Problem{Path: path, Message: fmt.Sprintf(format, args...), Warning: true})
+	}
+
+	for path, host := range map[string]string{"domains.nuxt": c.Domains.Nuxt, "domains.pocketbase": c.Domains.Pocketbase} {
+		switch {
+		case host == "":
+			add(path, "missing")
+		case !hostPattern.MatchString(host):
+			add(path, "%q is not a host name (no scheme or path, e.g. demo.pocketstore.io)", host)
+		}
+	}
+
+	if m := c.Maintenance; m != nil {
+		if m.Until != "" {
+			if until, err := parseUntil(m.Until); err != nil {
+				add("maintenance.until", "%v", err)
+			} else if _, err := time.Parse(time.RFC3339, m.Until); err != nil {
+				warn("maintenance.until", "%q is not RFC 3339, `go run bin/maintenance.go` normalises it", m.Until)
+			} else if m.Enabled && until.Before(time.Now()) {
+				warn("maintenance.until", "%s is in the past", m.Until)
+			}
+		}
+	}
+
+	if len(c.Extension) > 0 {
+		var b bool
+		var s string
+		var plugins map[string]ExtensionPlugin
+		switch {
+		case json.Unmarshal(c.Extension, &b) == nil:
+		case json.Unmarshal(c.Extension, &s) == nil:
+			if !strings.HasPrefix(s, "http://") && !strings.HasPrefix(s, "https://") {
+				add("extension", "%q is not an http(s) URL", s)
+			}
+		case json.Unmarshal(c.Extension, &plugins) == nil:
+			for _, key := range sortedPluginKeys(plugins) {
+				if strings.Count(key, "/") != 1 {
+					add("extension."+key, "plugin keys are vendor/name")
+				}
+				if plugins[key].Version == "" {
+					add("extension."+key+".version", "missing")
+				}
+			}
+		default:
+			add("extension", "must be a boolean, an extensions URL or a map of vendor/name to plugin")
+		}
+	}
+
+	for name, email := range c.Emails {
+		if _, err := mail.ParseAddress(email.Address); err != nil {
+			add("emails."+name+".address", "%q is not an email address", email.Address)
+		}
+	}
+
+	if len(c.Language.Languages) == 0 {
+		add("language.languages", "missing")
+	}
+	if !contains(c.Language.Languages, c.Language.Fallback) {
+		add("language.fallback", "%q is not one of language.languages %v", c.Language.Fallback, c.Language.Languages)
+	}
+	for i, l := range c.Language.Locales {
+		path := fmt.Sprintf("language.locales[%d]", i)
+		if !contains(c.Language.Languages, l.Code) {
+			add(path+".code", "%q is not one of language.languages %v", l.Code, c.Language.Languages)
+		}
+		if !strings.HasSuffix(l.File, ".json") {
+			add(path+".file", "%q is not a .json file", l.File)
+		}
+	}
+
+	if len(c.Currency.Currencies) == 0 {
+		add("currency.currencies", "missing")
+	}
+	if !contains(c.Currency.Currencies, c.Currency.Fallback) {
+		add("currency.fallback", "%q is not one of currency.currencies %v", c.Currency.Fallback, c.Currency.Currencies)
+	}
+
+	for name, integration := range c.Integrations {
+		if integration.ID == "" {
+			add("integrations."+name+".id", "missing")
+		}
+	}
+
+	if p := c.Payment.Paypal; p != nil {
+		if p.ID == "" {
+			add("payment.paypal.id", "missing")
+		}
+		if !currencyPattern.MatchString(p.Currency) {
+			add("payment.paypal.currency", "%q is not an ISO 4217 currency code like EUR", p.Currency)
+		}
+	}
+
+	if len(c.Layers) > 0 {
+		valid := len(c.Layers) == 3 && c.Layers[0] == "baseline" &&
+			((c.Layers[1] == "plugins" && c.Layers[2] == "custom") || (c.Layers[1] == "custom" && c.Layers[2] == "plugins"))
+		if !valid {
+			add("layers", "%v must list baseline first, then plugins and custom in either order", c.Layers)
+		}
+	}
+	return problems
+}
+
+func sortedPluginKeys(plugins map[string]ExtensionPlugin) []string {
+	keys := make([]string, 0, len(plugins))
+	for key := range plugins {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// envFromDotEnv returns the environment variable key, falling back to the .env file.
+// Keep in sync with bin/custom.go.
+func envFromDotEnv(key string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	data, err := os.ReadFile(".env")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		name, value, found := strings.Cut(strings.TrimSpace(line), "=")
+		if !found || strings.TrimSpace(strings.TrimPrefix(name, "export ")) != key {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		return value
+	}
+	return ""
+}
+
+// mergeConfig deep-merges overlay into base: objects are merged key by key, a null removes
+// the key and any other value replaces the one in base.
+// Keep in sync with bin/custom.go.
+func mergeConfig(base, overlay interface{}) interface{} {
+	b, ok1 := base.(map[string]interface{})
+	o, ok2 := overlay.(map[string]interface{})
+	if !ok1 || !ok2 {
+		return overlay
+	}
+	merged := make(map[string]interface{}, len(b))
+	for k, v := range b {
+		merged[k] = v
+	}
+	for k, v := range o {
+		if v == nil {
+			delete(merged, k)
+		} else if existing, ok := merged[k]; ok {
+			merged[k] = mergeConfig(existing, v)
+		} else {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// interpolateConfig replaces ${NAME} and ${NAME:-default} in every string of v with environment
+// variables (or .env), reporting the names that are not set and have no default.
+// Keep in sync with bin/custom.go.
+func interpolateConfig(v interface{}, path string, problems *[]Problem) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, item := range value {
+			value[k] = interpolateConfig(item, join(path, k), problems)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = interpolateConfig(item, fmt.Sprintf("%s[%d]", path, i), problems)
+		}
+	case string:
+		return envPattern.ReplaceAllStringFunc(value, func(match string) string {
+			m := envPattern.FindStringSubmatch(match)
+			if v := envFromDotEnv(m[1]); v != "" {
+				return v
+			}
+			if m[2] != "" {
+				return m[3]
+			}
+			*problems = append(*problems, Problem{Path: path, Message: fmt.Sprintf("environment variable %s is not set (add it to .env, see .env.example)", m[1])})
+			return match
+		})
+	}
+	return v
+}
+
+// overlayPath returns the overlay of the config at path for env, e.g. custom/pocketstore.stage.json
+func overlayPath(path, env string) string {
+	return strings.TrimSuffix(path, ".json") + "." + env + ".json"
+}
+
+// validate returns every problem found in the config at path, merged with its overlay for env
+// and interpolated the way bin/custom.go writes it to the storefront
+func validate(path, env string) ([]Problem, error) {
+	files := []string{path}
+	if env != "" {
+		if _, err := os.Stat(overlayPath(path, env)); err == nil {
+			files = append(files, overlayPath(path, env))
+		}
+	}
+
+	var merged interface{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		var layer interface{}
+		if err := decoder.Decode(&layer); err != nil {
+			p := decodeProblem(data, err)
+			p.Message = file + " " + p.Message
+			return []Problem{p}, nil
+		}
+		if merged == nil {
+			merged = layer
+		} else {
+			merged = mergeConfig(merged, layer)
+		}
+	}
+
+	var problems []Problem
+	merged = interpolateConfig(merged, "", &problems)
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return append(problems, decodeProblem(data, err)), nil
+	}
+	problems = append(problems, unknownKeys(data, reflect.TypeOf(config), "")...)
+	problems = append(problems, config.check()...)
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Path < problems[j].Path
+	})
+	return problems, nil
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "validate" {
+		fmt.Fprintln(os.Stderr, "usage: go run bin/config.go validate [--env stage] [custom/pocketstore.json]")
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	env := fs.String("env", envFromDotEnv("POCKETSTORE_ENV"), "environment whose overlay (pocketstore.<env>.json) is merged in")
+	fs.Parse(os.Args[2:])
+	path := configFile
+	if fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+
+	problems, err := validate(path, *env)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "FAILED: %v\n", err)
+		os.Exit(1)
+	}
+
+	name := path
+	if *env != "" {
+		name = fmt.Sprintf("%s (env %s)", path, *env)
+	}
+	var errorCount int
+	for _, p := range problems {
+		if p.Warning {
+			fmt.Printf("  warning %s\n", p)
+			continue
+		}
+		errorCount++
+		fmt.Printf("  ✗ %s\n", p)
+	}
+	if errorCount > 0 {
+		fmt.Fprintf(os.Stderr, "FAILED: %s has %d problem(s)\n", name, errorCount)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ %s is valid\n", name)
+}
@@ -1,14 +1,58 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 )
 
+var (
+	// buildLedger records the hash of every storefront file the build wrote, see bin/drift.go
+	buildLedger = ".plugins/storefront.json"
+	// customManifest lists the storefront files copied from custom/ on the previous run
+	customManifest = ".plugins/custom.json"
+	// configTarget is the resolved custom/pocketstore.json the storefront reads
+	configTarget = filepath.Join("storefront", "app", "pocketstore.json")
+	// configEnv selects the custom/pocketstore.<env>.json overlay, e.g. "stage"
+	configEnv = envFromDotEnv("POCKETSTORE_ENV")
+
+	watch    = flag.Bool("watch", false, "keep running and sync changes in custom/ into storefront/")
+	interval = flag.Duration("interval", 500*time.Millisecond, "polling interval for --watch")
+	link     = flag.Bool("link", false, "symlink custom/ files into storefront/ instead of copying them (development only)")
+)
+
+// written collects the files copyFile wrote since the last recordBuild
+var written []string
+
+// exportTargets maps a directory in custom/ to its target directory inside storefront/.
+// Keep in sync with bin/plugins.go.
+var exportTargets = map[string]string{
+	"pages":       "app/pages",
+	"components":  "app/components",
+	"layouts":     "app/layouts",
+	"public":      "public",
+	"utils":       "app/utils",
+	"composables": "app/composables",
+	"middleware":  "app/middleware",
+	"stores":      "app/stores",
+	"plugins":     "app/plugins",
+	"assets":      "app/assets",
+	"server/api":  "server/api",
+}
+
 // copyDirContents copies the contents of src into dst without creating the src folder itself.
-func copyDirContents(src, dst string) error {
+// Destination files listed in skip are left untouched. With link set files are symlinked instead.
+func copyDirContents(src, dst string, skip map[string]bool, link bool) error {
 	entries, err := os.ReadDir(src)
 	if err != nil {
 		return fmt.Errorf("failed to read directory %s: %w", src, err)
@@ -23,11 +67,15 @@ func copyDirContents(src, dst string) error {
 			if err := os.MkdirAll(dstPath, os.ModePerm); err != nil {
 				return fmt.Errorf("failed to create directory %s: %w", dstPath, err)
 			}
-			if err := copyDirContents(srcPath, dstPath); err != nil {
+			if err := copyDirContents(srcPath, dstPath, skip, link); err != nil {
 				return err
 			}
-		} else {
-			if err := copyFile(srcPath, dstPath); err != nil {
+		} else if !skip[dstPath] {
+			place := copyFile
+			if link {
+				place = linkFile
+			}
+			if err := place(srcPath, dstPath); err != nil {
 				return err
 			}
 		}
@@ -48,6 +96,13 @@ func copyFile(src, dst string) error {
 		return fmt.Errorf("failed to create directories for %s: %w", dst, err)
 	}
 
+	// Replace a symlink left by --link instead of writing through it into its source.
+	if info, err := os.Lstat(dst); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(dst); err != nil {
+			return fmt.Errorf("failed to remove symlink %s: %w", dst, err)
+		}
+	}
+
 	dstFile, err := os.Create(dst)
 	if err != nil {
 		return fmt.Errorf("failed to create destination file %s: %w", dst, err)
@@ -59,6 +114,8 @@ func copyFile(src, dst string) error {
 		return fmt.Errorf("failed to copy file from %s to %s: %w", src, dst, err)
 	}
 
+	written = append(written, dst)
+
 	// Copy file mode
 	if fi, err := srcFile.Stat(); err == nil {
 		if chmodErr := os.Chmod(dst, fi.Mode()); chmodErr != nil {
@@ -69,7 +126,607 @@ func copyFile(src, dst string) error {
 	return nil
 }
 
+// linkFile replaces dst with a relative symlink to src, so edits in storefront/ land in src.
+func linkFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directories for %s: %w", dst, err)
+	}
+	absSrc, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	absDir, err := filepath.Abs(filepath.Dir(dst))
+	if err != nil {
+		return err
+	}
+	target, err := filepath.Rel(absDir, absSrc)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to replace %s: %w", dst, err)
+	}
+	if err := os.Symlink(target, dst); err != nil {
+		return fmt.Errorf("failed to link %s to %s: %w", dst, src, err)
+	}
+	written = append(written, dst)
+	return nil
+}
+
+// placeFile copies src to dst, or symlinks it with --link
+func placeFile(src, dst string) error {
+	if *link {
+		return linkFile(src, dst)
+	}
+	return copyFile(src, dst)
+}
+
+// hasTheme reports whether custom/pocketstore.json declares "theme" tokens for bin/theme.go
+func hasTheme(custom string) bool {
+	var config struct {
+		Theme json.RawMessage `json:"theme"`
+	}
+	data, err := os.ReadFile(filepath.Join(custom, "pocketstore.json"))
+	return err == nil && json.Unmarshal(data, &config) == nil && len(config.Theme) > 0 && string(config.Theme) != "null"
+}
+
+// generateTheme regenerates storefront/daisyui.css from the theme tokens
+func generateTheme() {
+	cmd := exec.Command("go", "run", "bin/theme.go", "generate")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Printf("Error generating theme: %v\n", err)
+	}
+}
+
+// applyPatches writes the baseline files changed by custom/patches/*.patch into storefront/.
+// It also runs without patches, to restore files whose patch was removed.
+func applyPatches() error {
+	cmd := exec.Command("go", "run", "bin/patches.go", "apply")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// envFromDotEnv returns the environment variable key, falling back to the .env file so
+// scripts run on the host see the same values as docker compose
+func envFromDotEnv(key string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	data, err := os.ReadFile(".env")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		name, value, found := strings.Cut(strings.TrimSpace(line), "=")
+		if !found || strings.TrimSpace(strings.TrimPrefix(name, "export ")) != key {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		return value
+	}
+	return ""
+}
+
+// configOverlay returns the name of the pocketstore.json overlay for env, "" without env
+func configOverlay(env string) string {
+	if env == "" {
+		return ""
+	}
+	return "pocketstore." + env + ".json"
+}
+
+// envPattern matches ${NAME} and ${NAME:-default}
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// mergeConfig deep-merges overlay into base: objects are merged key by key, a null removes
+// the key and any other value replaces the one in base.
+// Keep in sync with bin/config.go.
+func mergeConfig(base, overlay interface{}) interface{} {
+	b, ok1 := base.(map[string]interface{})
+	o, ok2 := overlay.(map[string]interface{})
+	if !ok1 || !ok2 {
+		return overlay
+	}
+	merged := make(map[string]interface{}, len(b))
+	for k, v := range b {
+		merged[k] = v
+	}
+	for k, v := range o {
+		if v == nil {
+			delete(merged, k)
+		} else if existing, ok := merged[k]; ok {
+			merged[k] = mergeConfig(existing, v)
+		} else {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// interpolateConfig replaces ${NAME} in every string of v with environment variables (or .env),
+// collecting the names that are not set and have no default.
+// Keep in sync with bin/config.go.
+func interpolateConfig(v interface{}, missing map[string]bool) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, item := range value {
+			value[k] = interpolateConfig(item, missing)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = interpolateConfig(item, missing)
+		}
+	case string:
+		return envPattern.ReplaceAllStringFunc(value, func(match string) string {
+			m := envPattern.FindStringSubmatch(match)
+			if v := envFromDotEnv(m[1]); v != "" {
+				return v
+			}
+			if m[2] != "" {
+				return m[3]
+			}
+			missing[m[1]] = true
+			return match
+		})
+	}
+	return v
+}
+
+// resolveConfig returns custom/pocketstore.json merged with the overlay of env and interpolated.
+// Keep in sync with bin/config.go.
+func resolveConfig(custom, env string) (interface{}, error) {
+	names := []string{"pocketstore.json"}
+	if overlay := configOverlay(env); overlay != "" && fileExists(filepath.Join(custom, overlay)) {
+		names = append(names, overlay)
+	}
+
+	var config interface{}
+	for _, name := range names {
+		path := filepath.Join(custom, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		var layer interface{}
+		if err := decoder.Decode(&layer); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if config == nil {
+			config = layer
+		} else {
+			config = mergeConfig(config, layer)
+		}
+	}
+
+	missing := make(map[string]bool)
+	config = interpolateConfig(config, missing)
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("environment variables not set (add them to .env, see .env.example): %s", strings.Join(names, ", "))
+	}
+	return config, nil
+}
+
+// writeConfig writes the resolved custom/pocketstore.json to dst
+func writeConfig(custom, dst string) error {
+	config, err := resolveConfig(custom, configEnv)
+	if err != nil {
+		return err
+	}
+	// Storefront versions before the rename read the misspelled "maintance"; write both keys
+	// until every supported storefront reads "maintenance"
+	if m, ok := config.(map[string]interface{}); ok {
+		if v, ok := m["maintenance"]; ok {
+			m["maintance"] = v
+		}
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(config); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directories for %s: %w", dst, err)
+	}
+	// Never write through a symlink left by --link, the resolved file holds secrets
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.WriteFile(dst, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	written = append(written, dst)
+	return nil
+}
+
+// readLayers returns the layer precedence configured in custom/pocketstore.json ("layers"),
+// lowest first. See bin/layers.go.
+func readLayers() []string {
+	defaultLayers := []string{"baseline", "plugins", "custom"}
+	var config struct {
+		Layers []string `json:"layers"`
+	}
+	data, err := os.ReadFile("custom/pocketstore.json")
+	if err != nil || json.Unmarshal(data, &config) != nil || len(config.Layers) == 0 {
+		return defaultLayers
+	}
+	if !validLayers(config.Layers) {
+		if !layersWarned {
+			fmt.Fprintf(os.Stderr, "Warning: invalid \"layers\" %v in custom/pocketstore.json, using %v\n", config.Layers, defaultLayers)
+			layersWarned = true
+		}
+		return defaultLayers
+	}
+	return config.Layers
+}
+
+// layersWarned makes readLayers warn about invalid "layers" only once
+var layersWarned bool
+
+// validLayers reports whether layers names baseline, plugins and custom exactly once with
+// baseline first. Keep in sync with bin/layers.go.
+func validLayers(layers []string) bool {
+	if len(layers) != 3 || layers[0] != "baseline" {
+		return false
+	}
+	return (layers[1] == "plugins" && layers[2] == "custom") || (layers[1] == "custom" && layers[2] == "plugins")
+}
+
+// layerRank returns the position of layer in the layer precedence, higher wins
+func layerRank(layer string) int {
+	for i, l := range readLayers() {
+		if l == layer {
+			return i
+		}
+	}
+	return -1
+}
+
+// pluginFiles returns the storefront paths that bin/plugins.go copies from .plugins/repos,
+// mapped to the plugin file that ends up there (the lowest prio plugin wins)
+func pluginFiles(storefront string) map[string]string {
+	type pluginDir struct {
+		Path    string
+		Prio    int
+		Exports []string
+	}
+	var plugins []pluginDir
+	pluginJsons, _ := filepath.Glob(filepath.Join(".plugins", "repos", "*", "*", "plugin.json"))
+	legacy, _ := filepath.Glob(filepath.Join(".plugins", "repos", "*", "plugin.json"))
+	for _, pluginJson := range append(pluginJsons, legacy...) {
+		var pj struct {
+			Prio    int      `json:"prio"`
+			Exports []string `json:"exports"`
+		}
+		if data, err := os.ReadFile(pluginJson); err == nil {
+			_ = json.Unmarshal(data, &pj)
+		}
+		if len(pj.Exports) == 0 {
+			pj.Exports = []string{"pages", "components", "layouts", "public", "utils"}
+		}
+		plugins = append(plugins, pluginDir{Path: filepath.Dir(pluginJson), Prio: pj.Prio, Exports: pj.Exports})
+	}
+	sort.SliceStable(plugins, func(i, j int) bool {
+		return plugins[i].Prio > plugins[j].Prio
+	})
+
+	files := make(map[string]string)
+	for _, plugin := range plugins {
+		for _, dir := range plugin.Exports {
+			target, ok := exportTargets[dir]
+			if !ok {
+				continue
+			}
+			src := filepath.Join(plugin.Path, filepath.FromSlash(dir))
+			_ = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return nil
+				}
+				rel, _ := filepath.Rel(src, path)
+				files[filepath.Join(storefront, filepath.FromSlash(target), rel)] = path
+				return nil
+			})
+		}
+	}
+	return files
+}
+
+// removeStaleFiles handles storefront files copied from custom/ on the previous run whose
+// source is gone: the plugin or baseline version is restored, otherwise the file is removed.
+// current are the files copied on this run; the manifest is updated to match.
+func removeStaleFiles(current map[string]string, custom, storefront, baseline string) error {
+	var previous []string
+	if data, err := os.ReadFile(customManifest); err == nil {
+		if err := json.Unmarshal(data, &previous); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", customManifest, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	var plugins map[string]string
+	for _, path := range previous {
+		path = filepath.FromSlash(path)
+		if _, ok := current[path]; ok || themeGenerated(path, custom, storefront) {
+			continue
+		}
+		if plugins == nil {
+			plugins = pluginFiles(storefront)
+		}
+		restoreOrRemove(path, storefront, baseline, plugins)
+	}
+
+	paths := make([]string, 0, len(current))
+	for path := range current {
+		paths = append(paths, path)
+	}
+	return writeCustomManifest(paths)
+}
+
+// themeGenerated reports whether path is storefront/daisyui.css generated by bin/theme.go,
+// which must not be restored or removed when custom/daisyui.css goes away
+func themeGenerated(path, custom, storefront string) bool {
+	return path == filepath.Join(storefront, "daisyui.css") && hasTheme(custom)
+}
+
+// restoreOrRemove replaces the storefront file path, whose custom/ source is gone, with the
+// version of the next layer below custom: a plugin (plugins maps storefront paths to plugin
+// files) or baseline. Without one the file is removed.
+func restoreOrRemove(path, storefront, baseline string, plugins map[string]string) {
+	rel, _ := filepath.Rel(storefront, path)
+	if src, ok := plugins[path]; ok {
+		fmt.Printf("Restoring %s from %s...\n", path, src)
+		if err := placeFile(src, path); err != nil {
+			fmt.Printf("Error restoring %s: %v\n", path, err)
+		}
+	} else if src := filepath.Join(baseline, rel); fileExists(src) {
+		fmt.Printf("Restoring %s from %s...\n", path, src)
+		if err := placeFile(src, path); err != nil {
+			fmt.Printf("Error restoring %s: %v\n", path, err)
+		}
+	} else {
+		fmt.Printf("Removing stale %s...\n", path)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Error removing %s: %v\n", path, err)
+		}
+		// recordBuild drops removed files from the build ledger
+		written = append(written, path)
+	}
+}
+
+// writeCustomManifest stores the storefront files currently copied from custom/
+func writeCustomManifest(paths []string) error {
+	for i := range paths {
+		paths[i] = filepath.ToSlash(paths[i])
+	}
+	sort.Strings(paths)
+	out, err := json.MarshalIndent(paths, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(customManifest), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(customManifest, out, 0644)
+}
+
+// customSources maps every file bin/custom.go copies from custom/ to its storefront path
+func customSources(custom, storefront string) map[string]string {
+	sources := make(map[string]string)
+	for dir, target := range exportTargets {
+		src := filepath.Join(custom, filepath.FromSlash(dir))
+		_ = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return nil
+			}
+			rel, _ := filepath.Rel(src, path)
+			sources[path] = filepath.Join(storefront, filepath.FromSlash(target), rel)
+			return nil
+		})
+	}
+	for src, dst := range map[string]string{
+		"pocketstore.json":       filepath.Join("app", "pocketstore.json"),
+		configOverlay(configEnv): filepath.Join("app", "pocketstore.json"),
+		"daisyui.css":            "daisyui.css",
+	} {
+		if src == "daisyui.css" && hasTheme(custom) {
+			continue
+		}
+		if fileExists(filepath.Join(custom, src)) {
+			sources[filepath.Join(custom, src)] = filepath.Join(storefront, dst)
+		}
+	}
+	return sources
+}
+
+// fileState is what watchCustom compares to notice a changed file
+type fileState struct {
+	Target  string
+	ModTime time.Time
+	Size    int64
+}
+
+// watchCustom polls custom/ and syncs added, changed and deleted files into storefront/
+// until the process is stopped. Files in skip belong to a higher layer and are left alone.
+func watchCustom(custom, storefront, baseline string, skip map[string]bool, interval time.Duration) {
+	snapshot := func() map[string]fileState {
+		states := make(map[string]fileState)
+		for src, dst := range customSources(custom, storefront) {
+			if info, err := os.Stat(src); err == nil {
+				states[src] = fileState{Target: dst, ModTime: info.ModTime(), Size: info.Size()}
+			}
+		}
+		return states
+	}
+
+	fmt.Printf("Watching %s for changes (every %s, Ctrl+C to stop)...\n", custom, interval)
+	previous := snapshot()
+	for {
+		time.Sleep(interval)
+		current := snapshot()
+		changed, configChanged := false, false
+
+		for src, state := range current {
+			if old, ok := previous[src]; ok && old == state {
+				continue
+			}
+			changed = true
+			if skip[state.Target] {
+				continue
+			}
+			sync := placeFile
+			if state.Target == configTarget {
+				sync = func(string, string) error { return writeConfig(custom, state.Target) }
+				configChanged = true
+			}
+			if err := sync(src, state.Target); err != nil {
+				fmt.Printf("Error syncing %s: %v\n", src, err)
+				continue
+			}
+			fmt.Printf("Synced %s -> %s\n", src, state.Target)
+		}
+		// A removed environment overlay changes the resolved config
+		overlay := filepath.Join(custom, configOverlay(configEnv))
+		if _, ok := current[overlay]; !ok && configEnv != "" {
+			if _, ok := previous[overlay]; ok && fileExists(filepath.Join(custom, "pocketstore.json")) {
+				changed, configChanged = true, true
+				if err := writeConfig(custom, configTarget); err != nil {
+					fmt.Printf("Error syncing %s: %v\n", overlay, err)
+				} else {
+					fmt.Printf("Synced %s -> %s\n", filepath.Join(custom, "pocketstore.json"), configTarget)
+				}
+			}
+		}
+		copied := writtenBy("custom")
+
+		// Theme tokens live in pocketstore.json
+		if configChanged && hasTheme(custom) {
+			generateTheme()
+		}
+
+		var plugins map[string]string
+		for src, state := range previous {
+			if _, ok := current[src]; ok {
+				continue
+			}
+			changed = true
+			if skip[state.Target] {
+				continue
+			}
+			// A removed environment overlay was handled above
+			if state.Target == configTarget && fileExists(filepath.Join(custom, "pocketstore.json")) {
+				continue
+			}
+			if themeGenerated(state.Target, custom, storefront) {
+				continue
+			}
+			if plugins == nil {
+				plugins = pluginFiles(storefront)
+			}
+			restoreOrRemove(state.Target, storefront, baseline, plugins)
+		}
+		restored := writtenBy("restored")
+		// A restored baseline file may have patches
+		if len(restored) > 0 {
+			if err := applyPatches(); err != nil {
+				fmt.Printf("Error applying patches: %v\n", err)
+			}
+		}
+
+		if changed {
+			if err := recordBuild(copied); err != nil {
+				fmt.Printf("Error recording build: %v\n", err)
+			}
+			if err := recordBuild(restored); err != nil {
+				fmt.Printf("Error recording build: %v\n", err)
+			}
+			targets := make(map[string]bool)
+			for _, state := range current {
+				if !skip[state.Target] {
+					targets[state.Target] = true
+				}
+			}
+			paths := make([]string, 0, len(targets))
+			for path := range targets {
+				paths = append(paths, path)
+			}
+			if err := writeCustomManifest(paths); err != nil {
+				fmt.Printf("Error writing %s: %v\n", customManifest, err)
+			}
+		}
+		previous = current
+	}
+}
+
+// fileExists reports whether path exists and is a regular file
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// BuildRecord is the hash of a storefront file as the build wrote it
+type BuildRecord struct {
+	SHA256 string `json:"sha256"`
+	Layer  string `json:"layer"`
+}
+
+// recordBuild stores the hashes of the storefront files just written (path -> layer) in the
+// build ledger, which `go run bin/drift.go` compares the storefront against
+func recordBuild(files map[string]string) error {
+	ledger := make(map[string]BuildRecord)
+	if data, err := os.ReadFile(buildLedger); err == nil {
+		if err := json.Unmarshal(data, &ledger); err != nil {
+			return fmt.Errorf("error parsing %s: %v", buildLedger, err)
+		}
+	}
+	for path, layer := range files {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			delete(ledger, filepath.ToSlash(path))
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		ledger[filepath.ToSlash(path)] = BuildRecord{SHA256: fmt.Sprintf("%x", sha256.Sum256(data)), Layer: layer}
+	}
+	if err := os.MkdirAll(filepath.Dir(buildLedger), 0755); err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(ledger, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(buildLedger, out, 0644)
+}
+
+// writtenBy maps the files written since the last call to layer and resets written
+func writtenBy(layer string) map[string]string {
+	files := make(map[string]string)
+	for _, path := range written {
+		files[path] = layer
+	}
+	written = nil
+	return files
+}
+
 func main() {
+	flag.Parse()
+
 	baseline := "baseline"
 	storefront := "storefront"
 	custom := "custom"
@@ -78,52 +735,94 @@ func main() {
 	// Copy baseline to storefront if the target config file does not exist.
 	if _, err := os.Stat(targetConfig); os.IsNotExist(err) {
 		fmt.Println("Copying baseline to storefront...")
-		if err := copyDirContents(baseline, storefront); err != nil {
+		if err := copyDirContents(baseline, storefront, nil, false); err != nil {
 			fmt.Printf("Error copying baseline: %v\n", err)
 			return
 		}
+		if err := recordBuild(writtenBy("baseline")); err != nil {
+			fmt.Printf("Error recording build: %v\n", err)
+		}
 	}
 
-	// List of directories to override.
-	overrideDirs := []string{"public", "components", "pages", "layouts", "utils"}
-
-	for _, dir := range overrideDirs {
-		src := filepath.Join(custom, dir)
-		var dst string
-		if dir == "public" {
-			dst = filepath.Join(storefront, "public")
-		} else {
-			dst = filepath.Join(storefront, "app", dir)
+	// Files plugins provide are left alone when plugins rank above custom (see bin/layers.go).
+	var pluginOwned map[string]bool
+	if layerRank("plugins") > layerRank("custom") {
+		pluginOwned = make(map[string]bool)
+		for path := range pluginFiles(storefront) {
+			pluginOwned[path] = true
 		}
+	}
+
+	// Override storefront directories with their custom counterparts, sorted for stable output.
+	dirs := make([]string, 0, len(exportTargets))
+	for dir := range exportTargets {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		src := filepath.Join(custom, filepath.FromSlash(dir))
+		dst := filepath.Join(storefront, filepath.FromSlash(exportTargets[dir]))
 
 		// Only override if the source directory exists.
 		if _, err := os.Stat(src); err == nil {
 			fmt.Printf("Overriding %s -> %s...\n", src, dst)
-			if err := copyDirContents(src, dst); err != nil {
+			if err := copyDirContents(src, dst, pluginOwned, *link); err != nil {
 				fmt.Printf("Error overriding %s: %v\n", dir, err)
 			}
 		}
 	}
 
-	// Copy `custom/pocketstore.json` -> `storefront/app/pocketstore.json` if it exists.
-	pocketstoreSrc := filepath.Join(custom, "pocketstore.json")
-	pocketstoreDst := filepath.Join(storefront, "app", "pocketstore.json")
-	if _, err := os.Stat(pocketstoreSrc); err == nil {
-		fmt.Println("Copying custom/pocketstore.json to storefront...")
-		if err := copyFile(pocketstoreSrc, pocketstoreDst); err != nil {
-			fmt.Printf("Error copying pocketstore.json: %v\n", err)
+	// Resolve `custom/pocketstore.json` (plus the overlay of POCKETSTORE_ENV and ${ENV_VAR}s)
+	// into `storefront/app/pocketstore.json` if it exists.
+	if fileExists(filepath.Join(custom, "pocketstore.json")) {
+		if overlay := configOverlay(configEnv); overlay != "" && fileExists(filepath.Join(custom, overlay)) {
+			fmt.Printf("Writing custom/pocketstore.json + custom/%s to storefront...\n", overlay)
+		} else {
+			fmt.Println("Writing custom/pocketstore.json to storefront...")
+		}
+		if err := writeConfig(custom, configTarget); err != nil {
+			fmt.Printf("Error writing pocketstore.json: %v\n", err)
+			os.Exit(1)
 		}
 	}
 
-	// Copy `custom/daisyui.css` -> `storefront/daisyui.css` if it exists.
+	// Copy `custom/daisyui.css` -> `storefront/daisyui.css` if it exists, unless bin/theme.go
+	// generates it from "theme" in pocketstore.json.
 	daisySrc := filepath.Join(custom, "daisyui.css")
 	daisyDst := filepath.Join(storefront, "daisyui.css")
-	if _, err := os.Stat(daisySrc); err == nil {
+	if hasTheme(custom) {
+		fmt.Println("custom/pocketstore.json has a theme, storefront/daisyui.css is generated by bin/theme.go")
+	} else if _, err := os.Stat(daisySrc); err == nil {
 		fmt.Println("Copying custom/daisyui.css to storefront...")
-		if err := copyFile(daisySrc, daisyDst); err != nil {
+		if err := placeFile(daisySrc, daisyDst); err != nil {
 			fmt.Printf("Error copying daisyui.css: %v\n", err)
 		}
 	}
 
+	copied := writtenBy("custom")
+
+	// Undo copies whose custom/ source was deleted since the previous run.
+	if err := removeStaleFiles(copied, custom, storefront, baseline); err != nil {
+		fmt.Printf("Error removing stale files: %v\n", err)
+	}
+
+	if err := recordBuild(copied); err != nil {
+		fmt.Printf("Error recording build: %v\n", err)
+	}
+	if err := recordBuild(writtenBy("restored")); err != nil {
+		fmt.Printf("Error recording build: %v\n", err)
+	}
+
+	// Patch the baseline files custom/ and plugins leave alone, after restores so those are patched too.
+	if err := applyPatches(); err != nil {
+		fmt.Printf("Error applying patches: %v\n", err)
+		os.Exit(1)
+	}
+
 	fmt.Println("Copy complete.")
-}
\ No newline at end of file
+
+	if *watch {
+		watchCustom(custom, storefront, baseline, pluginOwned, *interval)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// BuildRecord is the hash of a storefront file as the build wrote it
+type BuildRecord struct {
+	SHA256 string `json:"sha256"`
+	Layer  string `json:"layer"`
+}
+
+var (
+	buildLedger = ".plugins/storefront.json"
+	storefront  = "storefront"
+	customRoot  = "custom"
+
+	extract = flag.Bool("extract", false, "copy modified storefront files into custom/ so the next build keeps them")
+
+	// exportTargets maps a directory in custom/ to its target directory inside storefront/.
+	// Keep in sync with bin/custom.go.
+	exportTargets = map[string]string{
+		"pages":       "app/pages",
+		"components":  "app/components",
+		"layouts":     "app/layouts",
+		"public":      "public",
+		"utils":       "app/utils",
+		"composables": "app/composables",
+		"middleware":  "app/middleware",
+		"stores":      "app/stores",
+		"plugins":     "app/plugins",
+		"assets":      "app/assets",
+		"server/api":  "server/api",
+	}
+
+	// customFiles maps single files in custom/ to their storefront path. app/pocketstore.json
+	// is resolved from custom/pocketstore*.json and .env, so it is never extracted.
+	customFiles = map[string]string{
+		"daisyui.css": "daisyui.css",
+	}
+)
+
+// customPath returns the file in custom/ that bin/custom.go copies to the storefront file path
+func customPath(path string) (string, bool) {
+	rel := strings.TrimPrefix(filepath.ToSlash(path), storefront+"/")
+	for src, dst := range customFiles {
+		if rel == dst {
+			return filepath.Join(customRoot, src), true
+		}
+	}
+	for dir, target := range exportTargets {
+		if strings.HasPrefix(rel, target+"/") {
+			return filepath.Join(customRoot, filepath.FromSlash(dir), filepath.FromSlash(strings.TrimPrefix(rel, target+"/"))), true
+		}
+	}
+	return "", false
+}
+
+func main() {
+	flag.Parse()
+
+	data, err := os.ReadFile(buildLedger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading %s (run the build first): %v\n", buildLedger, err)
+		os.Exit(2)
+	}
+	ledger := make(map[string]BuildRecord)
+	if err := json.Unmarshal(data, &ledger); err != nil {
+		fmt.Fprintf(os.Stderr, "error parsing %s: %v\n", buildLedger, err)
+		os.Exit(2)
+	}
+
+	paths := make([]string, 0, len(ledger))
+	for path := range ledger {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var drifted int
+	for _, path := range paths {
+		record := ledger[path]
+		// Files linked with --link are edited in their source, which is where they belong
+		if info, err := os.Lstat(filepath.FromSlash(path)); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			continue
+		}
+		content, err := os.ReadFile(filepath.FromSlash(path))
+		if os.IsNotExist(err) {
+			fmt.Printf("D %s (%s)\n", path, record.Layer)
+			drifted++
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: cannot read %s: %v\n", path, err)
+			continue
+		}
+		sum := fmt.Sprintf("%x", sha256.Sum256(content))
+		if sum == record.SHA256 {
+			continue
+		}
+		drifted++
+		fmt.Printf("M %s (%s)\n", path, record.Layer)
+
+		if !*extract {
+			continue
+		}
+		target, ok := customPath(path)
+		// A patched baseline file stays a patch rather than becoming a full override
+		if record.Layer == "patch" || !ok && record.Layer == "baseline" {
+			fmt.Printf("  keep the change as a patch: go run bin/patches.go create %s\n", path)
+			continue
+		}
+		if !ok {
+			fmt.Printf("  cannot extract: no custom/ counterpart for %s\n", path)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "  error creating %s: %v\n", filepath.Dir(target), err)
+			continue
+		}
+		if err := os.WriteFile(target, content, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "  error writing %s: %v\n", target, err)
+			continue
+		}
+		ledger[path] = BuildRecord{SHA256: sum, Layer: "custom"}
+		drifted--
+		fmt.Printf("  extracted to %s\n", target)
+	}
+
+	if *extract {
+		out, err := json.MarshalIndent(ledger, "", "  ")
+		if err == nil {
+			err = os.WriteFile(buildLedger, out, 0644)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error writing %s: %v\n", buildLedger, err)
+			os.Exit(2)
+		}
+	}
+
+	if drifted > 0 {
+		fmt.Printf("%d storefront file(s) differ from the build\n", drifted)
+		os.Exit(1)
+	}
+	fmt.Println("storefront matches the build")
+}
@@ -0,0 +1,608 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The storefront is assembled from layers. A path provided by several layers is taken from
+// the highest one. The default precedence, lowest first, is
+//
+//	baseline < plugins (by prio, lowest prio wins) < custom
+//
+// and can be changed with "layers" in custom/pocketstore.json, e.g. ["baseline", "custom", "plugins"].
+// The same order is applied to files (bin/plugins.go, bin/custom.go), translations
+// (bin/translations.go) and schema (bin/schema.go).
+//
+// Usage:
+//
+//	go run bin/layers.go list                                     print the layer stack
+//	go run bin/layers.go which app/components/Foo.vue             which layer provides a storefront file
+//	go run bin/layers.go which i18n/locales/de.json#cart.title    which layer provides a translation key
+//	go run bin/layers.go which schema#orders                      which layer provides a collection
+//	go run bin/layers.go overrides [--diff] [--accept] [path...]  custom and plugin files shadowing baseline files
+
+type Plugin struct {
+	Vendor   string
+	Name     string
+	Prio     int
+	BasePath string
+}
+
+type PluginJson struct {
+	Prio    int      `json:"prio"`
+	Exports []string `json:"exports,omitempty"`
+}
+
+// Layer is one source of storefront files, translations and schema
+type Layer struct {
+	Name    string // "baseline", "custom" or vendor/name of a plugin
+	Kind    string // "baseline", "plugins" or "custom"
+	Root    string
+	Prio    int
+	Exports []string // directories mapped through exportTargets, unused for baseline
+}
+
+var (
+	pluginRoot   = ".plugins/repos"
+	baselineRoot = "baseline"
+	customRoot   = "custom"
+
+	// overridesFile records the baseline version every override was based on. It lives in
+	// custom/ so it is committed together with the overrides.
+	overridesFile = filepath.Join(customRoot, "overrides.json")
+
+	// defaultLayers is the layer precedence, lowest first
+	defaultLayers = []string{"baseline", "plugins", "custom"}
+
+	// exportTargets maps a plugin or custom directory to its target directory inside storefront/.
+	// Keep in sync with bin/plugins.go and bin/custom.go.
+	exportTargets = map[string]string{
+		"pages":       "app/pages",
+		"components":  "app/components",
+		"layouts":     "app/layouts",
+		"public":      "public",
+		"utils":       "app/utils",
+		"composables": "app/composables",
+		"middleware":  "app/middleware",
+		"stores":      "app/stores",
+		"plugins":     "app/plugins",
+		"assets":      "app/assets",
+		"server/api":  "server/api",
+	}
+	defaultExports = []string{"pages", "components", "layouts", "public", "utils"}
+
+	// customFiles are single files bin/custom.go copies from custom/ into storefront/.
+	// app/pocketstore.json is config resolved per environment, not an override of the baseline one.
+	customFiles = map[string]string{
+		"pocketstore.json": "app/pocketstore.json",
+		"daisyui.css":      "daisyui.css",
+	}
+)
+
+// readLayers returns the layer precedence configured in custom/pocketstore.json, lowest first
+func readLayers() []string {
+	var config struct {
+		Layers []string `json:"layers"`
+	}
+	data, err := os.ReadFile(filepath.Join(customRoot, "pocketstore.json"))
+	if err != nil || json.Unmarshal(data, &config) != nil || len(config.Layers) == 0 {
+		return defaultLayers
+	}
+	if !validLayers(config.Layers) {
+		if !layersWarned {
+			fmt.Fprintf(os.Stderr, "Warning: invalid \"layers\" %v in custom/pocketstore.json, using %v\n", config.Layers, defaultLayers)
+			layersWarned = true
+		}
+		return defaultLayers
+	}
+	return config.Layers
+}
+
+// layersWarned makes readLayers warn about invalid "layers" only once
+var layersWarned bool
+
+// validLayers reports whether layers names baseline, plugins and custom exactly once with baseline first
+func validLayers(layers []string) bool {
+	if len(layers) != 3 || layers[0] != "baseline" {
+		return false
+	}
+	return (layers[1] == "plugins" && layers[2] == "custom") || (layers[1] == "custom" && layers[2] == "plugins")
+}
+
+// resolveLayers returns every layer, lowest precedence first
+func resolveLayers() []Layer {
+	var layers []Layer
+	for _, kind := range readLayers() {
+		switch kind {
+		case "baseline":
+			layers = append(layers, Layer{Name: "baseline", Kind: kind, Root: baselineRoot})
+		case "custom":
+			exports := make([]string, 0, len(exportTargets))
+			for dir := range exportTargets {
+				exports = append(exports, dir)
+			}
+			sort.Strings(exports)
+			layers = append(layers, Layer{Name: "custom", Kind: kind, Root: customRoot, Exports: exports})
+		case "plugins":
+			plugins := getPlugins()
+			// Highest prio first so the lowest prio plugin wins
+			sort.SliceStable(plugins, func(i, j int) bool {
+				return plugins[i].Prio > plugins[j].Prio
+			})
+			for _, p := range plugins {
+				name := p.Name
+				if p.Vendor != "" {
+					name = p.Vendor + "/" + p.Name
+				}
+				layers = append(layers, Layer{Name: name, Kind: kind, Root: p.BasePath, Prio: p.Prio, Exports: readExports(p.BasePath)})
+			}
+		}
+	}
+	return layers
+}
+
+// getPlugins lists installed plugins in .plugins/repos, sorted by key
+func getPlugins() []Plugin {
+	var plugins []Plugin
+	vendorDirs, err := os.ReadDir(pluginRoot)
+	if err != nil {
+		return plugins
+	}
+	for _, vendorEntry := range vendorDirs {
+		if !vendorEntry.IsDir() {
+			continue
+		}
+		vendorPath := filepath.Join(pluginRoot, vendorEntry.Name())
+
+		// Legacy single-level layout
+		if exists(filepath.Join(vendorPath, "plugin.json")) {
+			plugins = append(plugins, Plugin{Name: vendorEntry.Name(), Prio: readPrio(vendorPath), BasePath: vendorPath})
+			continue
+		}
+
+		pluginDirs, err := os.ReadDir(vendorPath)
+		if err != nil {
+			continue
+		}
+		for _, p := range pluginDirs {
+			pluginPath := filepath.Join(vendorPath, p.Name())
+			if p.IsDir() && exists(filepath.Join(pluginPath, "plugin.json")) {
+				plugins = append(plugins, Plugin{Vendor: vendorEntry.Name(), Name: p.Name(), Prio: readPrio(pluginPath), BasePath: pluginPath})
+			}
+		}
+	}
+	return plugins
+}
+
+func readPluginJson(dir string) PluginJson {
+	var pj PluginJson
+	data, err := os.ReadFile(filepath.Join(dir, "plugin.json"))
+	if err == nil {
+		_ = json.Unmarshal(data, &pj)
+	}
+	return pj
+}
+
+func readPrio(dir string) int {
+	return readPluginJson(dir).Prio
+}
+
+func readExports(dir string) []string {
+	if exports := readPluginJson(dir).Exports; len(exports) > 0 {
+		return exports
+	}
+	return defaultExports
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// sourceFile returns the file in layer l that ends up at the storefront-relative path target
+func (l Layer) sourceFile(target string) (string, bool) {
+	if l.Kind == "baseline" {
+		src := filepath.Join(l.Root, filepath.FromSlash(target))
+		return src, exists(src)
+	}
+	if l.Kind == "custom" {
+		for src, dst := range customFiles {
+			if dst == target && exists(filepath.Join(l.Root, src)) {
+				return filepath.Join(l.Root, src), true
+			}
+		}
+	}
+	for _, dir := range l.Exports {
+		prefix := exportTargets[dir] + "/"
+		if prefix == "/" || !strings.HasPrefix(target, prefix) {
+			continue
+		}
+		src := filepath.Join(l.Root, filepath.FromSlash(dir), filepath.FromSlash(strings.TrimPrefix(target, prefix)))
+		if exists(src) {
+			return src, true
+		}
+	}
+	return "", false
+}
+
+// translationFile returns the translations file of layer l for lang
+func (l Layer) translationFile(lang string) string {
+	return filepath.Join(l.Root, "translations", lang+".json")
+}
+
+// lookupKey reports whether the dotted key exists in the JSON object stored at path
+func lookupKey(path, key string) (interface{}, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var node interface{}
+	if err := json.Unmarshal(data, &node); err != nil {
+		return nil, false
+	}
+	for _, part := range strings.Split(key, ".") {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if node, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return node, true
+}
+
+// hasCollection reports whether the schema.json at path defines a collection named name
+func hasCollection(path, name string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	var collections []map[string]interface{}
+	if err := json.Unmarshal(data, &collections); err != nil {
+		return false
+	}
+	for _, c := range collections {
+		if c["name"] == name || c["id"] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// which prints every layer providing target, marking the one that wins
+func which(target string) error {
+	target = strings.TrimPrefix(filepath.ToSlash(target), "storefront/")
+
+	type provider struct {
+		layer  Layer
+		detail string
+	}
+	var providers []provider
+	for _, l := range resolveLayers() {
+		switch {
+		case strings.HasPrefix(target, "schema#"):
+			file := filepath.Join(l.Root, "schema.json")
+			if hasCollection(file, strings.TrimPrefix(target, "schema#")) {
+				providers = append(providers, provider{l, file})
+			}
+		case strings.HasPrefix(target, "i18n/locales/") && strings.Contains(target, "#"):
+			file, key, _ := strings.Cut(strings.TrimPrefix(target, "i18n/locales/"), "#")
+			src := l.translationFile(strings.TrimSuffix(file, ".json"))
+			if value, ok := lookupKey(src, key); ok {
+				providers = append(providers, provider{l, fmt.Sprintf("%s = %v", src, value)})
+			}
+		default:
+			if src, ok := l.sourceFile(target); ok {
+				providers = append(providers, provider{l, src})
+			}
+		}
+	}
+
+	if len(providers) == 0 {
+		return fmt.Errorf("no layer provides %s", target)
+	}
+	fmt.Printf("%s\n", target)
+	for i := len(providers) - 1; i >= 0; i-- {
+		marker := "  "
+		if i == len(providers)-1 {
+			marker = "✓ "
+		}
+		fmt.Printf("%s%-10s %-45s %s\n", marker, providers[i].layer.Kind, providers[i].layer.Name, providers[i].detail)
+	}
+	return nil
+}
+
+// list prints the layer stack, highest precedence first
+func list() {
+	layers := resolveLayers()
+	fmt.Printf("Layer order (lowest first): %s\n", strings.Join(readLayers(), " < "))
+	for i := len(layers) - 1; i >= 0; i-- {
+		l := layers[i]
+		if l.Kind == "plugins" {
+			fmt.Printf("  %-10s %s (prio: %d)\n", l.Kind, l.Name, l.Prio)
+		} else {
+			fmt.Printf("  %-10s %s\n", l.Kind, l.Root)
+		}
+	}
+}
+
+// OverrideRecord is the baseline version a custom or plugin file shadowing it was based on
+type OverrideRecord struct {
+	Target   string `json:"target"`   // storefront-relative path
+	Baseline string `json:"baseline"` // baseline commit, empty if baseline was not a git checkout
+	SHA256   string `json:"sha256"`   // hash of the baseline file the override was based on
+}
+
+// Override is a file of a plugin or custom layer that replaces a baseline file
+type Override struct {
+	Target string
+	Source string
+	Layer  Layer
+}
+
+// skipWalk are directories never copied into storefront/
+var skipWalk = map[string]bool{".git": true, "node_modules": true, ".nuxt": true, ".output": true}
+
+// layerFiles returns every storefront-relative path layer l provides, mapped to its source file
+func layerFiles(l Layer) map[string]string {
+	files := make(map[string]string)
+	for _, dir := range l.Exports {
+		target, ok := exportTargets[dir]
+		if !ok {
+			continue
+		}
+		root := filepath.Join(l.Root, filepath.FromSlash(dir))
+		_ = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if skipWalk[d.Name()] {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			rel, _ := filepath.Rel(root, path)
+			files[target+"/"+filepath.ToSlash(rel)] = path
+			return nil
+		})
+	}
+	if l.Kind == "custom" {
+		for src, dst := range customFiles {
+			if dst != "app/pocketstore.json" && exists(filepath.Join(l.Root, src)) {
+				files[dst] = filepath.Join(l.Root, src)
+			}
+		}
+	}
+	return files
+}
+
+// findOverrides returns every plugin and custom file shadowing a baseline file, sorted by source
+func findOverrides() []Override {
+	var overrides []Override
+	for _, l := range resolveLayers() {
+		if l.Kind == "baseline" {
+			continue
+		}
+		for target, src := range layerFiles(l) {
+			if exists(filepath.Join(baselineRoot, filepath.FromSlash(target))) {
+				overrides = append(overrides, Override{Target: target, Source: filepath.ToSlash(src), Layer: l})
+			}
+		}
+	}
+	sort.Slice(overrides, func(i, j int) bool {
+		return overrides[i].Source < overrides[j].Source
+	})
+	return overrides
+}
+
+// baselineGit runs git inside the baseline checkout and returns its trimmed output
+func baselineGit(args ...string) (string, error) {
+	out, err := exec.Command("git", append([]string{"-C", baselineRoot}, args...)...).Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+func shortCommit(commit string) string {
+	if commit == "" {
+		return "unknown commit"
+	}
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
+
+func fileHash(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+func readOverrideRecords() (map[string]OverrideRecord, error) {
+	records := make(map[string]OverrideRecord)
+	data, err := os.ReadFile(overridesFile)
+	if os.IsNotExist(err) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", overridesFile, err)
+	}
+	return records, nil
+}
+
+func writeOverrideRecords(records map[string]OverrideRecord) error {
+	if len(records) == 0 {
+		if err := os.Remove(overridesFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(records); err != nil {
+		return err
+	}
+	return os.WriteFile(overridesFile, buf.Bytes(), 0644)
+}
+
+// printBaselineDiff prints the upstream changes to a baseline file since the recorded commit
+func printBaselineDiff(record OverrideRecord) {
+	if record.Baseline == "" {
+		fmt.Printf("    no baseline commit recorded, cannot diff\n")
+		return
+	}
+	if _, err := baselineGit("cat-file", "-e", record.Baseline+"^{commit}"); err != nil {
+		fmt.Printf("    baseline commit %s is not available (shallow clone?), fetch it to see the diff\n", shortCommit(record.Baseline))
+		return
+	}
+	// Against the working tree, which is what bin/custom.go copies
+	diff, err := baselineGit("diff", "--no-color", record.Baseline, "--", record.Target)
+	if err != nil {
+		fmt.Printf("    error diffing %s: %v\n", record.Target, err)
+		return
+	}
+	for _, line := range strings.Split(diff, "\n") {
+		fmt.Printf("    %s\n", line)
+	}
+}
+
+// overrides reports every custom and plugin file shadowing a baseline file. Overrides seen for
+// the first time are recorded against the current baseline; an override whose baseline original
+// changed since is flagged until it is accepted.
+func overrides(args []string) error {
+	fs := flag.NewFlagSet("overrides", flag.ExitOnError)
+	showDiff := fs.Bool("diff", false, "print the upstream diff of every changed baseline file")
+	accept := fs.Bool("accept", false, "record the current baseline for the given overrides (all if none given) after porting the changes")
+	quiet := fs.Bool("q", false, "only record new overrides, print nothing")
+	fs.Parse(args)
+
+	only := make(map[string]bool)
+	for _, path := range fs.Args() {
+		only[strings.TrimPrefix(filepath.ToSlash(path), "storefront/")] = true
+	}
+
+	records, err := readOverrideRecords()
+	if err != nil {
+		return err
+	}
+
+	// Before the baseline submodule or the plugins are checked out every override would look
+	// removed, so leave the records alone
+	var missing string
+	if entries, err := os.ReadDir(baselineRoot); err != nil || len(entries) == 0 {
+		missing = baselineRoot + "/ is not checked out, run git submodule update --init"
+	}
+	for source := range records {
+		if missing == "" && strings.HasPrefix(source, pluginRoot+"/") && !exists(pluginRoot) {
+			missing = pluginRoot + " is missing, run go run bin/plugins.go first"
+		}
+	}
+	if missing != "" {
+		if *quiet {
+			return nil
+		}
+		return fmt.Errorf("%s", missing)
+	}
+	head, _ := baselineGit("rev-parse", "HEAD")
+
+	var changed, added int
+	found := findOverrides()
+	if !*quiet {
+		fmt.Printf("Overrides of baseline %s (%s):\n", shortCommit(head), overridesFile)
+	}
+	for _, o := range found {
+		sum, err := fileHash(filepath.Join(baselineRoot, filepath.FromSlash(o.Target)))
+		if err != nil {
+			return err
+		}
+		current := OverrideRecord{Target: o.Target, Baseline: head, SHA256: sum}
+		record, ok := records[o.Source]
+		marker, status := "✓", "based on "+shortCommit(record.Baseline)
+		switch {
+		case !ok:
+			records[o.Source] = current
+			added++
+			marker, status = "+", "recorded at "+shortCommit(head)
+		case record.SHA256 != sum && *accept && (len(only) == 0 || only[o.Source] || only[o.Target]):
+			records[o.Source] = current
+			status = fmt.Sprintf("accepted %s (was %s)", shortCommit(head), shortCommit(record.Baseline))
+		case record.SHA256 != sum:
+			changed++
+			marker, status = "!", "baseline changed since "+shortCommit(record.Baseline)
+		}
+		if *quiet {
+			continue
+		}
+		fmt.Printf("  %s %-45s %-10s %s  %s\n", marker, o.Target, o.Layer.Kind, o.Source, status)
+		if marker == "!" && *showDiff {
+			printBaselineDiff(record)
+		}
+	}
+
+	// Overrides deleted from custom/ or removed with their plugin
+	for source := range records {
+		if !exists(filepath.FromSlash(source)) {
+			delete(records, source)
+		}
+	}
+	if err := writeOverrideRecords(records); err != nil {
+		return fmt.Errorf("error writing %s: %v", overridesFile, err)
+	}
+
+	if *quiet {
+		return nil
+	}
+	fmt.Printf("%d override(s), %d new, %d with baseline changes\n", len(found), added, changed)
+	if changed > 0 {
+		if !*showDiff {
+			fmt.Println("Run with --diff to see the upstream changes.")
+		}
+		return fmt.Errorf("%d override(s) are based on an older baseline: port the changes, then run overrides --accept", changed)
+	}
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: go run bin/layers.go list | which <storefront path>[#key] | overrides [--diff] [--accept] [path...]")
+		os.Exit(2)
+	}
+	switch os.Args[1] {
+	case "list":
+		list()
+	case "which":
+		if len(os.Args) != 3 {
+			fmt.Fprintln(os.Stderr, "usage: go run bin/layers.go which <storefront path>[#key]")
+			os.Exit(2)
+		}
+		if err := which(os.Args[2]); err != nil {
+			fmt.Fprintf(os.Stderr, "FAILED: %v\n", err)
+			os.Exit(1)
+		}
+	case "overrides":
+		if err := overrides(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "FAILED: %v\n", err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q (available: list, which, overrides)\n", os.Args[1])
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Switches the storefront into maintenance mode and back:
+//
+//	go run bin/maintenance.go on [--until "2026-10-12T12:12:12+02:00"]
+//	go run bin/maintenance.go off
+//	go run bin/maintenance.go status
+//
+// on and off update "maintenance" in custom/pocketstore.json (normalising "until" to RFC 3339)
+// and regenerate .maintenance/index.html, a static page translated with the maintenance.*
+// keys of every configured language, and .maintenance/nginx.conf, which answers every request
+// with that page, 503 and a Retry-After of "until" while maintenance is on. Include it in the
+// server block of the storefront and reload nginx after switching.
+
+// Maintenance is the "maintenance" block of pocketstore.json
+type Maintenance struct {
+	Enabled bool   `json:"enabled"`
+	Until   string `json:"until,omitempty"`
+}
+
+// PageText is the translated text of the maintenance page for one language
+type PageText struct {
+	Lang    string
+	Title   string
+	Message string
+	Before  string // until text before and after the date
+	After   string
+}
+
+var (
+	maintenanceDir = ".maintenance"
+
+	// untilLayouts are the date formats accepted for "until". Keep in sync with bin/config.go.
+	untilLayouts = []string{
+		time.RFC3339,
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05",
+		"2006-01-02",
+		"02.01.2006 15:04:05",
+		"02.01.2006 15:04",
+		"02.01.2006",
+	}
+
+	// defaultTexts are used for languages whose translations lack maintenance.* keys
+	defaultTexts = map[string]map[string]string{
+		"en": {
+			"title":   "We'll be right back",
+			"message": "The shop is currently undergoing maintenance.",
+			"until":   "We expect to be back by {until}.",
+		},
+		"de": {
+			"title":   "Wir sind gleich zurück",
+			"message": "Der Shop wird gerade gewartet.",
+			"until":   "Voraussichtlich sind wir ab {until} wieder für dich da.",
+		},
+		"fr": {
+			"title":   "Nous revenons bientôt",
+			"message": "La boutique est actuellement en maintenance.",
+			"until":   "Nous serons de retour vers {until}.",
+		},
+	}
+
+	legacyKey = regexp.MustCompile(`"maintance"(\s*:)`)
+)
+
+// parseUntil parses an "until" date; dates without a zone are local time
+func parseUntil(s string) (time.Time, error) {
+	for _, layout := range untilLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a valid date (use RFC 3339, e.g. 2026-10-12T12:12:12+02:00, or DD.MM.YYYY HH:MM:SS)", s)
+}
+
+// findTopLevel returns the byte range of the value of key in the JSON object data,
+// or -1, -1 if the key is missing
+func findTopLevel(data []byte, key string) (int, int, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return 0, 0, fmt.Errorf("expected a JSON object")
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return 0, 0, err
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return 0, 0, err
+		}
+		if tok == key {
+			end := int(dec.InputOffset())
+			return end - len(raw), end, nil
+		}
+	}
+	return -1, -1, nil
+}
+
+// readMaintenance returns the maintenance block of the config at path
+func readMaintenance(path string) (Maintenance, error) {
+	var config struct {
+		Maintenance Maintenance `json:"maintenance"`
+		Legacy      Maintenance `json:"maintance"`
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Maintenance{}, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Maintenance{}, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if config.Maintenance == (Maintenance{}) {
+		return config.Legacy, nil
+	}
+	return config.Maintenance, nil
+}
+
+// writeMaintenance replaces the maintenance block of the config at path, leaving the rest of
+// the file as it is. The misspelled legacy key "maintance" is renamed.
+func writeMaintenance(path string, m Maintenance) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	data = legacyKey.ReplaceAll(data, []byte(`"maintenance"$1`))
+
+	value, err := json.MarshalIndent(m, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	start, end, err := findTopLevel(data, "maintenance")
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	var out []byte
+	if start >= 0 {
+		out = append(out, data[:start]...)
+		out = append(out, value...)
+		out = append(out, data[end:]...)
+	} else {
+		// Add the block as the last key
+		closing := bytes.LastIndexByte(data, '}')
+		body := bytes.TrimRight(data[:closing], " \t\r\n")
+		out = append(out, body...)
+		if !bytes.HasSuffix(body, []byte("{")) {
+			out = append(out, ',')
+		}
+		out = append(out, "\n  \"maintenance\": "...)
+		out = append(out, value...)
+		out = append(out, '\n')
+		out = append(out, data[closing:]...)
+	}
+	return os.WriteFile(path, out, 0644)
+}
+
+// readLanguages returns the configured languages, fallback first
+func readLanguages(path string) []string {
+	var config struct {
+		Language struct {
+			Fallback  string   `json:"fallback"`
+			Languages []string `json:"languages"`
+		} `json:"language"`
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || json.Unmarshal(data, &config) != nil || len(config.Language.Languages) == 0 {
+		return []string{"en"}
+	}
+	languages := []string{}
+	if config.Language.Fallback != "" {
+		languages = append(languages, config.Language.Fallback)
+	}
+	for _, l := range config.Language.Languages {
+		if l != config.Language.Fallback {
+			languages = append(languages, l)
+		}
+	}
+	return languages
+}
+
+// translation looks up maintenance.<key> for lang in the merged storefront translations
+// (bin/translations.go), then custom/translations, then the built-in defaults
+func translation(lang, key string) string {
+	for _, file := range []string{
+		filepath.Join("storefront", "i18n", "locales", lang+".json"),
+		filepath.Join("custom", "translations", lang+".json"),
+	} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var translations map[string]interface{}
+		if json.Unmarshal(data, &translations) != nil {
+			continue
+		}
+		if section, ok := translations["maintenance"].(map[string]interface{}); ok {
+			if s, ok := section[key].(string); ok && s != "" {
+				return s
+			}
+		}
+		if s, ok := translations["maintenance."+key].(string); ok && s != "" {
+			return s
+		}
+	}
+	if texts, ok := defaultTexts[lang]; ok {
+		return texts[key]
+	}
+	return defaultTexts["en"][key]
+}
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="{{(index .Texts 0).Lang}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{(index .Texts 0).Title}}</title>
+<style>
+body{margin:0;min-height:100vh;display:flex;align-items:center;justify-content:center;font-family:system-ui,sans-serif;background:#f4f4f5;color:#18181b;text-align:center}
+main{max-width:32rem;padding:2rem}
+section[hidden]{display:none}
+</style>
+</head>
+<body>
+<main>
+{{- range $i, $t := .Texts}}
+<section lang="{{$t.Lang}}"{{if $i}} hidden{{end}}>
+<h1>{{$t.Title}}</h1>
+<p>{{$t.Message}}</p>
+{{- if $.Until}}
+<p>{{$t.Before}}<time datetime="{{$.Until}}">{{$.UntilText}}</time>{{$t.After}}</p>
+{{- end}}
+</section>
+{{- end}}
+</main>
+<script>
+(function () {
+  var sections = document.querySelectorAll("section[lang]");
+  var wanted = (navigator.languages || [navigator.language]).map(function (l) { return l.slice(0, 2); });
+  for (var i = 0; i < wanted.length; i++) {
+    var match = document.querySelector('section[lang="' + wanted[i] + '"]');
+    if (!match) continue;
+    sections.forEach(function (s) { s.hidden = s !== match; });
+    document.documentElement.lang = wanted[i];
+    document.title = match.querySelector("h1").textContent;
+    break;
+  }
+  document.querySelectorAll("time[datetime]").forEach(function (t) {
+    var lang = t.closest("section").lang;
+    t.textContent = new Intl.DateTimeFormat(lang, { dateStyle: "long", timeStyle: "short" }).format(new Date(t.dateTime));
+  });
+})();
+</script>
+</body>
+</html>
+`))
+
+// writePage generates the translated static maintenance page
+func writePage(path string, languages []string, until time.Time) error {
+	data := struct {
+		Texts     []PageText
+		Until     string
+		UntilText string
+	}{}
+	if !until.IsZero() {
+		data.Until = until.Format(time.RFC3339)
+		data.UntilText = until.Format("2006-01-02 15:04 MST")
+	}
+	for _, lang := range languages {
+		before, after, _ := strings.Cut(translation(lang, "until"), "{until}")
+		data.Texts = append(data.Texts, PageText{
+			Lang:    lang,
+			Title:   translation(lang, "title"),
+			Message: translation(lang, "message"),
+			Before:  before,
+			After:   after,
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := pageTemplate.Execute(&buf, data); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// writeProxySnippet generates the nginx snippet serving the maintenance page with 503
+func writeProxySnippet(path, root string, m Maintenance, until time.Time) error {
+	var b strings.Builder
+	b.WriteString("# Generated by `go run bin/maintenance.go`, do not edit.\n")
+	b.WriteString("# Include inside the server block of the storefront and reload nginx after switching.\n")
+	if !m.Enabled {
+		b.WriteString("# Maintenance is off.\n")
+		return os.WriteFile(path, []byte(b.String()), 0644)
+	}
+	fmt.Fprintf(&b, "# Maintenance is on")
+	if !until.IsZero() {
+		fmt.Fprintf(&b, " until %s", m.Until)
+	}
+	b.WriteString(".\n\n")
+	b.WriteString("error_page 503 @maintenance;\n")
+	b.WriteString("return 503;\n\n")
+	b.WriteString("location @maintenance {\n")
+	fmt.Fprintf(&b, "    root %s;\n", root)
+	b.WriteString("    rewrite ^ /index.html break;\n")
+	if !until.IsZero() {
+		fmt.Fprintf(&b, "    add_header Retry-After \"%s\" always;\n", until.UTC().Format(http.TimeFormat))
+	}
+	b.WriteString("    add_header Cache-Control \"no-store\" always;\n")
+	b.WriteString("}\n")
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// apply writes m to the config and regenerates the page and proxy snippet
+func apply(config string, m Maintenance) error {
+	var until time.Time
+	if m.Until != "" {
+		t, err := parseUntil(m.Until)
+		if err != nil {
+			return err
+		}
+		until = t
+		m.Until = t.Format(time.RFC3339)
+	}
+
+	if err := writeMaintenance(config, m); err != nil {
+		return fmt.Errorf("failed to update %s: %v", config, err)
+	}
+
+	if err := os.MkdirAll(maintenanceDir, 0755); err != nil {
+		return err
+	}
+	root, err := filepath.Abs(maintenanceDir)
+	if err != nil {
+		return err
+	}
+	page := filepath.Join(maintenanceDir, "index.html")
+	if err := writePage(page, readLanguages(config), until); err != nil {
+		return fmt.Errorf("failed to write %s: %v", page, err)
+	}
+	snippet := filepath.Join(maintenanceDir, "nginx.conf")
+	if err := writeProxySnippet(snippet, root, m, until); err != nil {
+		return fmt.Errorf("failed to write %s: %v", snippet, err)
+	}
+
+	state := "off"
+	if m.Enabled {
+		state = "on"
+		if m.Until != "" {
+			state += " until " + m.Until
+		}
+	}
+	fmt.Printf("✓ Maintenance %s (%s)\n", state, config)
+	fmt.Printf("  page  -> %s\n  nginx -> %s\n", page, snippet)
+	fmt.Println("  Run `go run bin/custom.go` to update the storefront and reload nginx.")
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: go run bin/maintenance.go on [--until <date>] | off | status")
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	config := fs.String("config", "custom/pocketstore.json", "config to update, e.g. custom/pocketstore.stage.json")
+	untilFlag := fs.String("until", "", "end of the maintenance window (RFC 3339 or DD.MM.YYYY HH:MM:SS, local time without zone)")
+	fs.Parse(os.Args[2:])
+
+	current, err := readMaintenance(*config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "FAILED: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch os.Args[1] {
+	case "on":
+		m := Maintenance{Enabled: true, Until: current.Until}
+		if *untilFlag != "" {
+			m.Until = *untilFlag
+		}
+		if m.Until != "" {
+			if t, err := parseUntil(m.Until); err == nil && t.Before(time.Now()) {
+				err = fmt.Errorf("until %s is in the past, pass a new --until", t.Format(time.RFC3339))
+				fmt.Fprintf(os.Stderr, "FAILED: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		err = apply(*config, m)
+	case "off":
+		err = apply(*config, Maintenance{Enabled: false})
+	case "status":
+		if !current.Enabled {
+			fmt.Println("Maintenance is off")
+		} else if current.Until != "" {
+			fmt.Printf("Maintenance is on until %s\n", current.Until)
+		} else {
+			fmt.Println("Maintenance is on")
+		}
+	default:
+		err = fmt.Errorf("unknown command %q (available: on, off, status)", os.Args[1])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "FAILED: %v\n", err)
+		os.Exit(1)
+	}
+}
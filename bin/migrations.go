@@ -0,0 +1,295 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Plugin struct {
+	Version  string `json:"version"`
+	Name     string `json:"name"`
+	Vendor   string `json:"vendor"`
+	Revision string `json:"revision,omitempty"`
+}
+
+type PluginJson struct {
+	Requirements []string `json:"requirements,omitempty"`
+}
+
+// Migration is a single migration file shipped by a plugin
+type Migration struct {
+	File      string `json:"file"`
+	Source    string `json:"source"`
+	Plugin    string `json:"plugin"`
+	Revision  string `json:"revision,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+var (
+	pluginRoot        = ".plugins/repos"
+	installedFile     = ".plugins/installed.json"
+	migrationsRoot    = ".migrations"
+	migrationManifest = ".plugins/migrations.json"
+)
+
+func main() {
+	// Pattern: .plugins/repos/*/*/migrations/*.js
+	matches, err := filepath.Glob(filepath.Join(pluginRoot, "*", "*", "migrations", "*.js"))
+	if err != nil {
+		panic(err)
+	}
+
+	revisions := readRevisions(installedFile)
+
+	// Group migrations by plugin key (vendor/name)
+	byPlugin := make(map[string][]Migration)
+	for _, file := range matches {
+		pluginDir := filepath.Dir(filepath.Dir(file))
+		key := filepath.Base(filepath.Dir(pluginDir)) + "/" + filepath.Base(pluginDir)
+		base := filepath.Base(file)
+
+		ts, ok := parseTimestamp(base)
+		if !ok {
+			fmt.Printf("Skipping %s: filename must start with a unix timestamp (e.g. 1700000000_create_orders.js)\n", file)
+			continue
+		}
+		byPlugin[key] = append(byPlugin[key], Migration{
+			Source:    file,
+			Plugin:    key,
+			Revision:  revisions[key],
+			Timestamp: ts,
+		})
+	}
+
+	previous, err := readManifest(migrationManifest)
+	if err != nil {
+		fmt.Printf("Error reading %s: %v\n", migrationManifest, err)
+		os.Exit(1)
+	}
+
+	if err := os.MkdirAll(migrationsRoot, 0755); err != nil {
+		panic(err)
+	}
+
+	// PocketBase records applied migrations by filename, so a name once written never changes.
+	// It applies them sorted by filename: a new migration named earlier than the last migration
+	// of a plugin it depends on is an error, the plugin has to rename it.
+	written := make(map[string]Migration)
+	for _, m := range previous {
+		written[m.Source] = m
+	}
+	order, deps := dependencyOrder(byPlugin)
+	latest := make(map[string]int64)
+	var result []Migration
+	var misordered int
+	for _, key := range order {
+		migrations := byPlugin[key]
+		sort.Slice(migrations, func(i, j int) bool {
+			if migrations[i].Timestamp != migrations[j].Timestamp {
+				return migrations[i].Timestamp < migrations[j].Timestamp
+			}
+			return migrations[i].Source < migrations[j].Source
+		})
+
+		var floor int64
+		for _, dep := range deps[key] {
+			if latest[dep] > floor {
+				floor = latest[dep]
+			}
+		}
+
+		for _, m := range migrations {
+			prev, applied := written[m.Source]
+			if m.Timestamp <= floor {
+				if !applied {
+					fmt.Printf("Error: %s predates a migration of a required plugin (%d), rename it to a later timestamp\n", m.Source, floor)
+					misordered++
+					continue
+				}
+				fmt.Printf("  Warning: %s predates a migration of a required plugin (%d), keeping its name %s\n", m.Source, floor, prev.File)
+			}
+			if m.Timestamp > floor {
+				floor = m.Timestamp
+			}
+
+			m.File = migrationName(m)
+			if applied {
+				m.File = prev.File
+			}
+			if err := copyFile(m.Source, filepath.Join(migrationsRoot, m.File)); err != nil {
+				fmt.Printf("Error copying %s: %v\n", m.Source, err)
+				// Keep a written migration, so it is neither removed below nor renamed next run
+				if applied {
+					result = append(result, prev)
+				}
+				continue
+			}
+			result = append(result, m)
+		}
+		latest[key] = floor
+		fmt.Printf("Collected %d migrations from %s\n", len(migrations), key)
+	}
+
+	// Remove migrations that were generated by a previous run but no longer exist
+	current := make(map[string]bool)
+	for _, m := range result {
+		current[m.File] = true
+	}
+	for _, m := range previous {
+		if current[m.File] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(migrationsRoot, m.File)); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Error removing stale migration %s: %v\n", m.File, err)
+			continue
+		}
+		fmt.Printf("Removed stale migration %s (was %s)\n", m.File, m.Plugin)
+	}
+
+	outData, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile(migrationManifest, outData, 0644); err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Migrations written to %s (%d total files)\n", migrationsRoot, len(result))
+	if misordered > 0 {
+		fmt.Fprintf(os.Stderr, "FAILED: %d migration(s) would run before the migrations they depend on\n", misordered)
+		os.Exit(1)
+	}
+}
+
+// parseTimestamp extracts the leading unix timestamp of a PocketBase migration filename
+func parseTimestamp(name string) (int64, bool) {
+	prefix, _, found := strings.Cut(name, "_")
+	if !found {
+		return 0, false
+	}
+	ts, err := strconv.ParseInt(prefix, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return ts, true
+}
+
+// migrationName returns the filename a new migration is written to in .migrations, keeping the
+// timestamp first and prefixing the original name with the plugin,
+// e.g. "1700000000_pocketstore-io.plugin-checkout.create_orders.js"
+func migrationName(m Migration) string {
+	_, rest, _ := strings.Cut(filepath.Base(m.Source), "_")
+	return fmt.Sprintf("%d_%s.%s", m.Timestamp, strings.ReplaceAll(m.Plugin, "/", "."), rest)
+}
+
+// dependencyOrder returns the plugin keys sorted so that requirements come before the
+// plugins requiring them, together with each plugin's requirements.
+func dependencyOrder(byPlugin map[string][]Migration) ([]string, map[string][]string) {
+	keys := make([]string, 0, len(byPlugin))
+	for key := range byPlugin {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	deps := make(map[string][]string)
+	for _, key := range keys {
+		deps[key] = readRequirements(key)
+	}
+
+	var order []string
+	state := make(map[string]int) // 0 = unvisited, 1 = visiting, 2 = done
+	var visit func(key string)
+	visit = func(key string) {
+		switch state[key] {
+		case 1:
+			fmt.Printf("Warning: circular requirement involving %s\n", key)
+			return
+		case 2:
+			return
+		}
+		state[key] = 1
+		for _, dep := range deps[key] {
+			visit(dep)
+		}
+		state[key] = 2
+		if _, ok := byPlugin[key]; ok {
+			order = append(order, key)
+		}
+	}
+	for _, key := range keys {
+		visit(key)
+	}
+	return order, deps
+}
+
+// readRequirements returns the vendor/name keys a plugin requires in its plugin.json
+func readRequirements(key string) []string {
+	data, err := os.ReadFile(filepath.Join(pluginRoot, filepath.FromSlash(key), "plugin.json"))
+	if err != nil {
+		return nil
+	}
+	var pj PluginJson
+	if err := json.Unmarshal(data, &pj); err != nil {
+		return nil
+	}
+	var keys []string
+	for _, req := range pj.Requirements {
+		parts := strings.Split(req, "/")
+		if len(parts) < 2 {
+			continue
+		}
+		name := parts[len(parts)-1]
+		if !strings.HasPrefix(name, "plugin-") {
+			name = "plugin-" + name
+		}
+		keys = append(keys, parts[len(parts)-2]+"/"+name)
+	}
+	return keys
+}
+
+// readRevisions maps vendor/name to the revision recorded in installed.json
+func readRevisions(path string) map[string]string {
+	revisions := make(map[string]string)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return revisions
+	}
+	var plugins []Plugin
+	if err := json.Unmarshal(data, &plugins); err != nil {
+		return revisions
+	}
+	for _, p := range plugins {
+		revisions[p.Vendor+"/"+p.Name] = p.Revision
+	}
+	return revisions
+}
+
+// readManifest reads the migrations written by the previous run
+func readManifest(path string) ([]Migration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var migrations []Migration
+	if err := json.Unmarshal(data, &migrations); err != nil {
+		return nil, err
+	}
+	return migrations, nil
+}
+
+// copyFile copies a file from src to dst
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0644)
+}
@@ -0,0 +1,725 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Small changes to baseline files are kept as unified diffs in custom/patches/*.patch instead of
+// overriding the whole file in custom/. Paths are relative to the storefront root:
+//
+//	--- a/app/components/Foo.vue
+//	+++ b/app/components/Foo.vue
+//
+// Usage:
+//
+//	go run bin/patches.go [apply]                 patch the baseline files into storefront/ (run by bin/custom.go)
+//	go run bin/patches.go create <path> [name]    save the edits of storefront/<path> as custom/patches/<name>.patch
+//	go run bin/patches.go refresh                 re-create every patch against the current baseline
+//
+// Patches are applied in file name order to the pristine baseline file, so applying is repeatable.
+// A hunk whose context moved is applied at the new position; a hunk whose context changed fails
+// the build. Files a plugin or custom/ provides are left alone, they replace the baseline file.
+
+// FilePatch is the part of a patch that changes one file
+type FilePatch struct {
+	Old   string // storefront-relative path, empty for a new file
+	New   string // storefront-relative path, empty for a deleted file
+	Hunks []Hunk
+}
+
+// Hunk is one @@ section of a FilePatch
+type Hunk struct {
+	Header   string
+	OldStart int
+	Old      []string // lines including their newline, as in the file before the patch
+	New      []string
+}
+
+// BuildRecord is the hash of a storefront file as the build wrote it
+type BuildRecord struct {
+	SHA256 string `json:"sha256"`
+	Layer  string `json:"layer"`
+}
+
+var (
+	patchDir   = filepath.Join("custom", "patches")
+	baseline   = "baseline"
+	storefront = "storefront"
+	custom     = "custom"
+	// patchManifest lists the storefront files patched on the previous run
+	patchManifest = ".plugins/patches.json"
+	buildLedger   = ".plugins/storefront.json"
+
+	fixHint = "edit the patch by hand, or delete it, redo the change in storefront/ and run patches create"
+
+	// exportTargets maps a directory in custom/ or a plugin to its target directory inside storefront/.
+	// Keep in sync with bin/custom.go.
+	exportTargets = map[string]string{
+		"pages":       "app/pages",
+		"components":  "app/components",
+		"layouts":     "app/layouts",
+		"public":      "public",
+		"utils":       "app/utils",
+		"composables": "app/composables",
+		"middleware":  "app/middleware",
+		"stores":      "app/stores",
+		"plugins":     "app/plugins",
+		"assets":      "app/assets",
+		"server/api":  "server/api",
+	}
+)
+
+// patchPath strips the a/ or b/ prefix and any timestamp from a ---/+++ line
+func patchPath(field string) string {
+	path, _, _ := strings.Cut(field, "\t")
+	path = strings.TrimSpace(path)
+	if path == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(path, "a/") || strings.HasPrefix(path, "b/") {
+		path = path[2:]
+	}
+	return strings.TrimPrefix(path, "storefront/")
+}
+
+// parseRange parses "12,3" (or "12", a count of 1) from a hunk header
+func parseRange(s string) (start, count int, err error) {
+	startText, countText, found := strings.Cut(s, ",")
+	if start, err = strconv.Atoi(startText); err != nil {
+		return 0, 0, err
+	}
+	count = 1
+	if found {
+		count, err = strconv.Atoi(countText)
+	}
+	return start, count, err
+}
+
+// parsePatch reads the file patches of a unified diff. Text outside of them, like a leading
+// description or git's "diff --git" and "index" lines, is ignored.
+func parsePatch(data string) ([]FilePatch, error) {
+	lines := strings.SplitAfter(data, "\n")
+	var patches []FilePatch
+	for i := 0; i < len(lines); i++ {
+		if !strings.HasPrefix(lines[i], "--- ") || i+1 >= len(lines) || !strings.HasPrefix(lines[i+1], "+++ ") {
+			continue
+		}
+		fp := FilePatch{
+			Old: patchPath(strings.TrimSuffix(lines[i][4:], "\n")),
+			New: patchPath(strings.TrimSuffix(lines[i+1][4:], "\n")),
+		}
+		i += 2
+		for i < len(lines) && strings.HasPrefix(lines[i], "@@ ") {
+			header := strings.TrimSuffix(lines[i], "\n")
+			fields := strings.Fields(header)
+			if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+				return nil, fmt.Errorf("line %d: invalid hunk header %q", i+1, header)
+			}
+			oldStart, oldCount, err := parseRange(fields[1][1:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid hunk header %q", i+1, header)
+			}
+			_, newCount, err := parseRange(fields[2][1:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid hunk header %q", i+1, header)
+			}
+			hunk := Hunk{Header: header, OldStart: oldStart}
+			i++
+			// The counts, not the line prefixes, tell where a hunk ends: a removed "-- x" line
+			// looks like the next file header
+			for oldCount > 0 || newCount > 0 {
+				if i >= len(lines) {
+					return nil, fmt.Errorf("%s: hunk %q is truncated", fp.Target(), header)
+				}
+				line := lines[i]
+				if line == "" {
+					return nil, fmt.Errorf("%s: hunk %q is truncated", fp.Target(), header)
+				}
+				if line == "\n" {
+					line = " \n" // editors strip the space of empty context lines
+				}
+				text := line[1:]
+				if !strings.HasSuffix(text, "\n") {
+					text += "\n"
+				}
+				noNewline := i+1 < len(lines) && strings.HasPrefix(lines[i+1], "\\")
+				if noNewline {
+					text = strings.TrimSuffix(text, "\n")
+				}
+				switch line[0] {
+				case ' ':
+					hunk.Old = append(hunk.Old, text)
+					hunk.New = append(hunk.New, text)
+					oldCount--
+					newCount--
+				case '-':
+					hunk.Old = append(hunk.Old, text)
+					oldCount--
+				case '+':
+					hunk.New = append(hunk.New, text)
+					newCount--
+				default:
+					return nil, fmt.Errorf("line %d: unexpected %q in hunk %q", i+1, strings.TrimSuffix(line, "\n"), header)
+				}
+				i++
+				if noNewline {
+					i++
+				}
+			}
+			fp.Hunks = append(fp.Hunks, hunk)
+		}
+		i--
+		if fp.Old == "" && fp.New == "" {
+			return nil, errors.New("patch of /dev/null")
+		}
+		patches = append(patches, fp)
+	}
+	return patches, nil
+}
+
+// Target is the storefront-relative path the file patch writes
+func (fp FilePatch) Target() string {
+	if fp.New != "" {
+		return fp.New
+	}
+	return fp.Old
+}
+
+// matches reports whether old is found in lines at position at
+func matches(lines, old []string, at int) bool {
+	if at < 0 || at+len(old) > len(lines) {
+		return false
+	}
+	for i := range old {
+		if lines[at+i] != old[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// HunkResult tells where a hunk applied, Line is 0 if it did not
+type HunkResult struct {
+	Hunk   Hunk
+	Line   int
+	Offset int
+}
+
+// applyHunks applies the hunks of fp to content. Hunks are searched for at their line number
+// first and then further and further away, as long as they stay in order.
+func applyHunks(content string, fp FilePatch) (string, []HunkResult) {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var out []string
+	var results []HunkResult
+	cursor, shift := 0, 0
+	for _, h := range fp.Hunks {
+		expected := h.OldStart - 1 + shift
+		if len(h.Old) == 0 {
+			// Pure additions are placed after line OldStart
+			expected = h.OldStart + shift
+		}
+		expected = min(max(expected, cursor), len(lines))
+		found := -1
+		for d := 0; found < 0 && (expected-d >= cursor || expected+d <= len(lines)); d++ {
+			if expected-d >= cursor && matches(lines, h.Old, expected-d) {
+				found = expected - d
+			} else if d > 0 && matches(lines, h.Old, expected+d) {
+				found = expected + d
+			}
+		}
+		if found < 0 {
+			results = append(results, HunkResult{Hunk: h})
+			continue
+		}
+		results = append(results, HunkResult{Hunk: h, Line: found + 1, Offset: found - expected})
+		shift += found - expected
+		out = append(out, lines[cursor:found]...)
+		out = append(out, h.New...)
+		cursor = found + len(h.Old)
+	}
+	out = append(out, lines[cursor:]...)
+	return strings.Join(out, ""), results
+}
+
+// Patched is the content of a storefront file after all patches touching it
+type Patched struct {
+	Content string
+	Deleted bool
+}
+
+// patchFiles returns custom/patches/*.patch in application order
+func patchFiles() []string {
+	files, _ := filepath.Glob(filepath.Join(patchDir, "*.patch"))
+	sort.Strings(files)
+	return files
+}
+
+// baselineCommit returns the short commit of the baseline checkout, for error messages
+func baselineCommit() string {
+	out, err := exec.Command("git", "-C", baseline, "rev-parse", "--short", "HEAD").Output()
+	if err != nil {
+		return "unknown commit"
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// readBaseline returns the content of a baseline file, patched by the earlier patches in state
+func readBaseline(state map[string]Patched, target string) (string, bool) {
+	if p, ok := state[target]; ok {
+		return p.Content, !p.Deleted
+	}
+	data, err := os.ReadFile(filepath.Join(baseline, filepath.FromSlash(target)))
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// Failure is a hunk that did not apply
+type Failure struct {
+	Patch  string
+	Target string
+	Index  int
+	Hunk   Hunk
+	Reason string
+}
+
+// PatchStep is one file patch with the content of its file before and after it
+type PatchStep struct {
+	Patch  string
+	FP     FilePatch
+	Before string
+	After  Patched
+}
+
+// applyAll applies every patch in order on top of the baseline. It returns the resulting
+// files, every step taken (for refresh) and the hunks that failed.
+func applyAll(verbose bool) (map[string]Patched, []PatchStep, []Failure, error) {
+	state := make(map[string]Patched)
+	var steps []PatchStep
+	var failures []Failure
+	for _, file := range patchFiles() {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		fps, err := parsePatch(string(data))
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("%s: %v", file, err)
+		}
+		if len(fps) == 0 {
+			return nil, nil, nil, fmt.Errorf("%s: no file changes found, is it a unified diff?", file)
+		}
+		for _, fp := range fps {
+			target := fp.Target()
+			before, ok := readBaseline(state, target)
+			if !ok && fp.Old != "" {
+				failures = append(failures, Failure{Patch: file, Target: target, Reason: "file does not exist in baseline"})
+				continue
+			}
+			if ok && fp.Old == "" {
+				failures = append(failures, Failure{Patch: file, Target: target, Reason: "creates a file that exists in baseline"})
+				continue
+			}
+			after, results := applyHunks(before, fp)
+			failed := false
+			for i, r := range results {
+				if r.Line == 0 {
+					failures = append(failures, Failure{Patch: file, Target: target, Index: i + 1, Hunk: r.Hunk})
+					failed = true
+				} else if r.Offset != 0 && verbose {
+					fmt.Printf("  %s: hunk #%d of %s applied at line %d (offset %+d, run patches refresh)\n", file, i+1, target, r.Line, r.Offset)
+				}
+			}
+			if failed {
+				continue
+			}
+			result := Patched{Content: after, Deleted: fp.New == ""}
+			state[target] = result
+			steps = append(steps, PatchStep{Patch: file, FP: fp, Before: before, After: result})
+		}
+	}
+	return state, steps, failures, nil
+}
+
+// reportFailures prints every failed hunk with the lines it expected to find
+func reportFailures(failures []Failure) {
+	commit := baselineCommit()
+	for _, f := range failures {
+		if f.Index == 0 {
+			fmt.Fprintf(os.Stderr, "✗ %s: %s %s (baseline %s)\n", f.Patch, f.Target, f.Reason, commit)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "✗ %s: hunk #%d %s does not apply to %s (baseline %s), expected:\n", f.Patch, f.Index, f.Hunk.Header, f.Target, commit)
+		for _, line := range f.Hunk.Old {
+			fmt.Fprintf(os.Stderr, "    %s\n", strings.TrimSuffix(line, "\n"))
+		}
+	}
+}
+
+// pluginTargets returns the storefront-relative paths bin/plugins.go copies from .plugins/repos.
+// Keep in sync with pluginFiles in bin/custom.go.
+func pluginTargets() map[string]bool {
+	targets := make(map[string]bool)
+	pluginJsons, _ := filepath.Glob(filepath.Join(".plugins", "repos", "*", "*", "plugin.json"))
+	legacy, _ := filepath.Glob(filepath.Join(".plugins", "repos", "*", "plugin.json"))
+	for _, pluginJson := range append(pluginJsons, legacy...) {
+		var pj struct {
+			Exports []string `json:"exports"`
+		}
+		if data, err := os.ReadFile(pluginJson); err == nil {
+			_ = json.Unmarshal(data, &pj)
+		}
+		if len(pj.Exports) == 0 {
+			pj.Exports = []string{"pages", "components", "layouts", "public", "utils"}
+		}
+		for _, dir := range pj.Exports {
+			target, ok := exportTargets[dir]
+			if !ok {
+				continue
+			}
+			src := filepath.Join(filepath.Dir(pluginJson), filepath.FromSlash(dir))
+			_ = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return nil
+				}
+				rel, _ := filepath.Rel(src, path)
+				targets[target+"/"+filepath.ToSlash(rel)] = true
+				return nil
+			})
+		}
+	}
+	return targets
+}
+
+// replaced reports whether custom/ or a plugin provides target, which then wins over the patch
+func replaced(target string, plugins map[string]bool) bool {
+	if plugins[target] {
+		return true
+	}
+	for dir, prefix := range exportTargets {
+		if rel, ok := strings.CutPrefix(target, prefix+"/"); ok && fileExists(filepath.Join(custom, filepath.FromSlash(dir), filepath.FromSlash(rel))) {
+			return true
+		}
+	}
+	return false
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// writeFile writes content to path unless it is already there, replacing a symlink
+func writeFile(path, content string) error {
+	if data, err := os.ReadFile(path); err == nil && string(data) == content {
+		if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+	}
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// recordBuild stores the hashes of the storefront files just written (path -> layer) in the
+// build ledger, which `go run bin/drift.go` compares the storefront against
+func recordBuild(files map[string]string) error {
+	ledger := make(map[string]BuildRecord)
+	if data, err := os.ReadFile(buildLedger); err == nil {
+		if err := json.Unmarshal(data, &ledger); err != nil {
+			return fmt.Errorf("error parsing %s: %v", buildLedger, err)
+		}
+	}
+	for path, layer := range files {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			delete(ledger, filepath.ToSlash(path))
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		ledger[filepath.ToSlash(path)] = BuildRecord{SHA256: fmt.Sprintf("%x", sha256.Sum256(data)), Layer: layer}
+	}
+	if err := os.MkdirAll(filepath.Dir(buildLedger), 0755); err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(ledger, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(buildLedger, out, 0644)
+}
+
+// apply writes the patched baseline files into storefront/ and restores the baseline version
+// of files patched on the previous run whose patch is gone
+func apply() error {
+	state, _, failures, err := applyAll(true)
+	if err != nil {
+		return err
+	}
+	if len(failures) > 0 {
+		reportFailures(failures)
+		return fmt.Errorf("%d patch hunk(s) failed: %s", len(failures), fixHint)
+	}
+
+	var previous []string
+	if data, err := os.ReadFile(patchManifest); err == nil {
+		if err := json.Unmarshal(data, &previous); err != nil {
+			return fmt.Errorf("error parsing %s: %v", patchManifest, err)
+		}
+	}
+
+	plugins := pluginTargets()
+	written := make(map[string]string)
+	targets := make([]string, 0, len(state))
+	for target := range state {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	for _, target := range targets {
+		if replaced(target, plugins) {
+			fmt.Printf("  %s is provided by custom/ or a plugin, its patch has no effect\n", target)
+			continue
+		}
+		dst := filepath.Join(storefront, filepath.FromSlash(target))
+		if state[target].Deleted {
+			if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		} else if err := writeFile(dst, state[target].Content); err != nil {
+			return err
+		}
+		written[dst] = "patch"
+		fmt.Printf("Patched %s\n", dst)
+	}
+
+	for _, target := range previous {
+		if _, ok := state[target]; ok || replaced(target, plugins) {
+			continue
+		}
+		dst := filepath.Join(storefront, filepath.FromSlash(target))
+		if data, err := os.ReadFile(filepath.Join(baseline, filepath.FromSlash(target))); err == nil {
+			fmt.Printf("Restoring %s from %s...\n", dst, baseline)
+			err = writeFile(dst, string(data))
+			written[dst] = "baseline"
+			if err != nil {
+				return err
+			}
+		} else if err := os.Remove(dst); err == nil {
+			fmt.Printf("Removing %s...\n", dst)
+			written[dst] = "baseline"
+		}
+	}
+
+	if err := recordBuild(written); err != nil {
+		return fmt.Errorf("error recording build: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(patchManifest), 0755); err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(targets, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(patchManifest, out, 0644)
+}
+
+// diff returns the unified diff from before to after for target, with a/ and b/ prefixes
+func diff(target, before, after string, created, deleted bool) (string, error) {
+	tmp, err := os.MkdirTemp("", "pocketstore-patch")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	oldPath, newPath := "/dev/null", "/dev/null"
+	for _, side := range []struct {
+		path    *string
+		prefix  string
+		content string
+		skip    bool
+	}{{&oldPath, "a", before, created}, {&newPath, "b", after, deleted}} {
+		if side.skip {
+			continue
+		}
+		*side.path = side.prefix + "/" + target
+		file := filepath.Join(tmp, side.prefix, filepath.FromSlash(target))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(file, []byte(side.content), 0644); err != nil {
+			return "", err
+		}
+	}
+
+	cmd := exec.Command("git", "diff", "--no-index", "--no-prefix", "--no-color", oldPath, newPath)
+	cmd.Dir = tmp
+	out, err := cmd.Output()
+	// git diff exits 1 when the files differ
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+		return "", fmt.Errorf("git diff: %v", err)
+	}
+	return string(out), nil
+}
+
+// create saves the difference between the (patched) baseline and storefront/<target> as a new patch
+func create(target, name string) error {
+	target = strings.TrimPrefix(filepath.ToSlash(target), "storefront/")
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(target), filepath.Ext(target))
+	}
+	file := filepath.Join(patchDir, strings.TrimSuffix(name, ".patch")+".patch")
+	if fileExists(file) {
+		return fmt.Errorf("%s already exists", file)
+	}
+
+	state, _, failures, err := applyAll(false)
+	if err != nil {
+		return err
+	}
+	if len(failures) > 0 {
+		reportFailures(failures)
+		return fmt.Errorf("fix the existing patches first")
+	}
+	before, ok := readBaseline(state, target)
+	if !ok {
+		return fmt.Errorf("%s is not a baseline file, add it to custom/ instead", target)
+	}
+	after, err := os.ReadFile(filepath.Join(storefront, filepath.FromSlash(target)))
+	deleted := os.IsNotExist(err)
+	if err != nil && !deleted {
+		return err
+	}
+	if string(after) == before && !deleted {
+		return fmt.Errorf("storefront/%s does not differ from baseline", target)
+	}
+
+	out, err := diff(target, before, string(after), false, deleted)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(patchDir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, []byte(out), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s\n", file)
+	return nil
+}
+
+// refresh re-creates every patch that still applies against the current baseline, so line
+// numbers and context match it again. Patches with failed hunks are left untouched.
+func refresh() error {
+	_, steps, failures, err := applyAll(false)
+	if err != nil {
+		return err
+	}
+	failedPatches := make(map[string]bool)
+	for _, f := range failures {
+		failedPatches[f.Patch] = true
+	}
+
+	sections := make(map[string][]string)
+	var order []string
+	for _, step := range steps {
+		if _, ok := sections[step.Patch]; !ok {
+			order = append(order, step.Patch)
+		}
+		out, err := diff(step.FP.Target(), step.Before, step.After.Content, step.FP.Old == "", step.After.Deleted)
+		if err != nil {
+			return err
+		}
+		sections[step.Patch] = append(sections[step.Patch], out)
+	}
+
+	for _, file := range order {
+		if failedPatches[file] {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		// Keep the description above the first file of the patch
+		var description string
+		for _, line := range strings.SplitAfter(string(data), "\n") {
+			if strings.HasPrefix(line, "diff ") || strings.HasPrefix(line, "--- ") {
+				break
+			}
+			description += line
+		}
+		updated := description + strings.Join(sections[file], "")
+		if updated == string(data) {
+			fmt.Printf("  %s is up to date\n", file)
+			continue
+		}
+		if err := os.WriteFile(file, []byte(updated), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("  refreshed %s\n", file)
+	}
+
+	if len(failures) > 0 {
+		reportFailures(failures)
+		return fmt.Errorf("%d patch hunk(s) no longer apply: %s", len(failures), fixHint)
+	}
+	return nil
+}
+
+func main() {
+	command := "apply"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+
+	var err error
+	switch command {
+	case "apply":
+		if len(patchFiles()) > 0 {
+			fmt.Printf("Applying %s to baseline %s...\n", patchDir, baselineCommit())
+		}
+		err = apply()
+	case "create":
+		if len(os.Args) < 3 || len(os.Args) > 4 {
+			fmt.Fprintln(os.Stderr, "usage: go run bin/patches.go create <storefront path> [name]")
+			os.Exit(2)
+		}
+		name := ""
+		if len(os.Args) == 4 {
+			name = os.Args[3]
+		}
+		err = create(os.Args[2], name)
+	case "refresh":
+		fmt.Printf("Refreshing %s against baseline %s...\n", patchDir, baselineCommit())
+		err = refresh()
+	default:
+		err = fmt.Errorf("unknown command %q (available: apply, create, refresh)", command)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "FAILED: %v\n", err)
+		os.Exit(1)
+	}
+}
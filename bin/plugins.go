@@ -2,8 +2,14 @@ package main
 
 import (
 	"archive/zip"
-	"crypto/sha1"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
+	"flag"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,6 +17,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -25,10 +32,21 @@ type Plugin struct {
 }
 
 type PluginJson struct {
-	Prio         int      `json:"prio"`
-	Revision     string   `json:"revision,omitempty"`
-	Version      string   `json:"version,omitempty"`
-	Requirements []string `json:"requirements,omitempty"`
+	Vendor          string            `json:"vendor,omitempty"`
+	Prio            int               `json:"prio"`
+	Revision        string            `json:"revision,omitempty"`
+	Version         string            `json:"version,omitempty"`
+	Requirements    []string          `json:"requirements,omitempty"`
+	Exports         []string          `json:"exports,omitempty"` // directories copied into the storefront, see exportTargets
+	Nuxt            *NuxtJson         `json:"nuxt,omitempty"`
+	NpmDependencies map[string]string `json:"npmDependencies,omitempty"`
+}
+
+// NuxtJson holds the nuxt.config contributions a plugin declares in plugin.json
+type NuxtJson struct {
+	Modules       []string               `json:"modules,omitempty"`
+	RuntimeConfig map[string]interface{} `json:"runtimeConfig,omitempty"`
+	Head          map[string]interface{} `json:"head,omitempty"` // merged into app.head
 }
 
 type PocketstoreConfig struct {
@@ -68,10 +86,75 @@ type RemoteExtensions struct {
 }
 
 var (
-	pluginRoot = ".plugins/repos"
-	dirsToCopy = []string{"pages", "components", "layouts", "public", "utils"}
+	// downloadURL and extensionsURL can point to a registry started with
+	// `go run bin/registry.go serve`, e.g. for private plugins or offline runs
+	downloadURL   = envOr("POCKETSTORE_DOWNLOAD_URL", "https://download.pocketstore.io")
+	extensionsURL = envOr("POCKETSTORE_EXTENSIONS_URL", "https://plugins.pocketstore.io/extensions.json")
+
+	pluginRoot    = ".plugins/repos"
+	hooksRoot     = ".hooks"
+	hooksManifest = ".plugins/hooks.json"
+	nuxtConfig    = "storefront/pocketstore.plugins.config.ts"
+	packageJSON   = "storefront/package.json"
+	npmManifest   = ".plugins/npm.json"
+	manifestRoot  = ".plugins/manifests"
+	buildLedger   = ".plugins/storefront.json"
+
+	// link symlinks exported plugin files into storefront/ instead of copying them, so edits
+	// land in .plugins/repos (development only, see `unlink`)
+	link = flag.Bool("link", false, "symlink plugin files into storefront/ instead of copying them")
+
+	// exportTargets maps a plugin export to its target directory inside storefront/.
+	// Keep in sync with bin/custom.go.
+	exportTargets = map[string]string{
+		"pages":       "app/pages",
+		"components":  "app/components",
+		"layouts":     "app/layouts",
+		"public":      "public",
+		"utils":       "app/utils",
+		"composables": "app/composables",
+		"middleware":  "app/middleware",
+		"stores":      "app/stores",
+		"plugins":     "app/plugins",
+		"assets":      "app/assets",
+		"server/api":  "server/api",
+	}
+	// defaultExports is used for plugins whose plugin.json does not declare "exports"
+	defaultExports = []string{"pages", "components", "layouts", "public", "utils"}
 )
 
+// envOr returns the environment variable key, or the value in .env, or fallback when it is unset
+func envOr(key, fallback string) string {
+	if v := envFromDotEnv(key); v != "" {
+		return strings.TrimRight(v, "/")
+	}
+	return fallback
+}
+
+// envFromDotEnv returns the environment variable key, falling back to the .env file so
+// scripts run on the host see the same values as docker compose. Keep in sync with bin/custom.go.
+func envFromDotEnv(key string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	data, err := os.ReadFile(".env")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		name, value, found := strings.Cut(strings.TrimSpace(line), "=")
+		if !found || strings.TrimSpace(strings.TrimPrefix(name, "export ")) != key {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		return value
+	}
+	return ""
+}
+
 // readPluginsFromFile reads and parses a plugins JSON file
 func readPluginsFromFile(filePath string) ([]Plugin, error) {
 	data, err := os.ReadFile(filePath)
@@ -132,9 +215,11 @@ func parsePluginURL(url string) (vendor, name string, ok bool) {
 	return vendor, name, true
 }
 
-// copyDir recursively copies a directory
-func copyDir(src, dst string) error {
-	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+// copyDir recursively copies a directory, leaving target files listed in skip untouched.
+// It returns the target files written.
+func copyDir(src, dst string, skip map[string]bool) ([]string, error) {
+	var written []string
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -143,8 +228,80 @@ func copyDir(src, dst string) error {
 		if info.IsDir() {
 			return os.MkdirAll(targetPath, 0755)
 		}
-		return copyFile(path, targetPath)
+		if skip[targetPath] {
+			return nil
+		}
+		place := copyFile
+		if *link {
+			place = linkFile
+		}
+		if err := place(path, targetPath); err != nil {
+			return err
+		}
+		written = append(written, targetPath)
+		return nil
 	})
+	return written, err
+}
+
+// readLayers returns the layer precedence configured in custom/pocketstore.json ("layers"),
+// lowest first. See bin/layers.go.
+func readLayers() []string {
+	defaultLayers := []string{"baseline", "plugins", "custom"}
+	var config struct {
+		Layers []string `json:"layers"`
+	}
+	data, err := os.ReadFile("custom/pocketstore.json")
+	if err != nil || json.Unmarshal(data, &config) != nil || len(config.Layers) == 0 {
+		return defaultLayers
+	}
+	if !validLayers(config.Layers) {
+		if !layersWarned {
+			fmt.Fprintf(os.Stderr, "Warning: invalid \"layers\" %v in custom/pocketstore.json, using %v\n", config.Layers, defaultLayers)
+			layersWarned = true
+		}
+		return defaultLayers
+	}
+	return config.Layers
+}
+
+// layersWarned makes readLayers warn about invalid "layers" only once
+var layersWarned bool
+
+// validLayers reports whether layers names baseline, plugins and custom exactly once with
+// baseline first. Keep in sync with bin/layers.go.
+func validLayers(layers []string) bool {
+	if len(layers) != 3 || layers[0] != "baseline" {
+		return false
+	}
+	return (layers[1] == "plugins" && layers[2] == "custom") || (layers[1] == "custom" && layers[2] == "plugins")
+}
+
+// layerRank returns the position of layer in the layer precedence, higher wins
+func layerRank(layer string) int {
+	for i, l := range readLayers() {
+		if l == layer {
+			return i
+		}
+	}
+	return -1
+}
+
+// customFiles returns the storefront paths that bin/custom.go copies from custom/
+func customFiles() map[string]bool {
+	files := make(map[string]bool)
+	for dir, target := range exportTargets {
+		src := filepath.Join("custom", filepath.FromSlash(dir))
+		_ = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return nil
+			}
+			rel, _ := filepath.Rel(src, path)
+			files[filepath.Join("storefront", filepath.FromSlash(target), rel)] = true
+			return nil
+		})
+	}
+	return files
 }
 
 // copyFile copies a file from src to dst
@@ -157,6 +314,12 @@ func copyFile(src, dst string) error {
 	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
 		return err
 	}
+	// Replace a symlink left by --link instead of writing through it into its source
+	if info, err := os.Lstat(dst); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(dst); err != nil {
+			return err
+		}
+	}
 	out, err := os.Create(dst)
 	if err != nil {
 		return err
@@ -168,6 +331,114 @@ func copyFile(src, dst string) error {
 	return out.Chmod(0644)
 }
 
+// linkFile replaces dst with a relative symlink to src
+func linkFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	absSrc, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	absDir, err := filepath.Abs(filepath.Dir(dst))
+	if err != nil {
+		return err
+	}
+	target, err := filepath.Rel(absDir, absSrc)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Symlink(target, dst)
+}
+
+// linkedPluginDirs returns the plugin directories in .plugins/repos that storefront/ files are
+// symlinked into by --link, e.g. ".plugins/repos/vendor/plugin-name"
+func linkedPluginDirs() map[string]bool {
+	dirs := make(map[string]bool)
+	repos, err := filepath.Abs(filepath.Join(".plugins", "repos"))
+	if err != nil {
+		return dirs
+	}
+	_ = filepath.Walk("storefront", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() && info.Name() == "node_modules" {
+			return filepath.SkipDir
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		abs, err := filepath.Abs(target)
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(repos, abs)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return nil
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if len(parts) > 2 {
+			dirs[".plugins/repos/"+parts[0]+"/"+parts[1]] = true
+		}
+		return nil
+	})
+	return dirs
+}
+
+// unlinkStorefront replaces every symlink in storefront/ left by --link with a copy of the
+// file it points to, switching the storefront back to copy mode for production builds:
+// plugins unlink
+func unlinkStorefront(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: go run bin/plugins.go unlink")
+	}
+	var count int
+	err := filepath.Walk("storefront", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == "node_modules" {
+			return filepath.SkipDir
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			fmt.Printf("  Removing dangling link %s\n", path)
+			count++
+			return os.Remove(path)
+		}
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✓ Replaced %d symlinks in storefront/ with copies\n", count)
+	return nil
+}
+
 // DownloadFile downloads a file from the given URL and saves it to the given filepath
 // returns the HTTP status code and an error (if any)
 func DownloadFile(filepathDest string, url string) (int, error) {
@@ -260,7 +531,7 @@ func Unzip(src, dest string) error {
 
 // FetchLatestVersion queries the plugin API for the latest version string
 func FetchLatestVersion(vendor, name string) (string, error) {
-	url := fmt.Sprintf("https://download.pocketstore.io/d/plugins/%s/%s/latest.zip", vendor, name)
+	url := fmt.Sprintf("%s/d/plugins/%s/%s/latest.zip", downloadURL, vendor, name)
 	resp, err := http.Head(url)
 	if err != nil {
 		return "", err
@@ -323,53 +594,103 @@ func tryReadGitHead(pluginDir string) string {
 	return ""
 }
 
-// computeDirSHA1 computes a SHA1 over the files contained in dir in a deterministic way
-// Returns hex-encoded sha1. This is used as a fallback "commit-like" identifier when no
-// revision is provided by plugin.json and no .git data is present.
-func computeDirSHA1(dir string) (string, error) {
-	var files []string
+// PluginManifest records the SHA-256 of every file of an installed plugin and of the
+// storefront files it produced, so `plugins verify` can tell exactly what changed
+type PluginManifest struct {
+	Vendor     string            `json:"vendor"`
+	Name       string            `json:"name"`
+	Version    string            `json:"version,omitempty"`
+	Revision   string            `json:"revision,omitempty"`
+	Files      map[string]string `json:"files"`                // path relative to the plugin dir -> sha256
+	Storefront map[string]string `json:"storefront,omitempty"` // storefront file copied from the plugin -> sha256
+}
+
+// manifestPath returns where the manifest of vendor/name is stored
+func manifestPath(vendor, name string) string {
+	return filepath.Join(manifestRoot, vendor, name+".json")
+}
+
+// readManifest reads the manifest of vendor/name
+func readManifest(vendor, name string) (PluginManifest, error) {
+	var m PluginManifest
+	data, err := os.ReadFile(manifestPath(vendor, name))
+	if err != nil {
+		return m, err
+	}
+	err = json.Unmarshal(data, &m)
+	return m, err
+}
+
+// writeManifest writes the manifest of m.Vendor/m.Name
+func writeManifest(m PluginManifest) error {
+	path := manifestPath(m.Vendor, m.Name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, out, 0644)
+}
+
+// hashFile returns the hex-encoded SHA-256 of the file at path
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// hashDir returns the SHA-256 of every file below dir, keyed by slash-separated relative path.
+// .git and common volatile files are skipped.
+func hashDir(dir string) (map[string]string, error) {
+	files := make(map[string]string)
 	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		base := filepath.Base(path)
 		if info.IsDir() {
+			if base == ".git" {
+				return filepath.SkipDir
+			}
 			return nil
 		}
-		// skip common volatile files
-		base := filepath.Base(path)
 		if base == ".DS_Store" || base == "Thumbs.db" {
 			return nil
 		}
-		files = append(files, path)
+		sum, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files[filepath.ToSlash(rel)] = sum
 		return nil
 	})
-	if err != nil {
-		return "", err
+	return files, err
+}
+
+// manifestDigest combines the per-file hashes into a single SHA-256. This is used as a
+// fallback "commit-like" identifier when no revision is provided by plugin.json and
+// no .git data is present.
+func manifestDigest(files map[string]string) string {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
 	}
-	sort.Strings(files)
-	h := sha1.New()
-	for _, f := range files {
-		rel, _ := filepath.Rel(dir, f)
-		// include filename to avoid collisions
-		if _, err := h.Write([]byte(rel)); err != nil {
-			return "", err
-		}
-		if _, err := h.Write([]byte{0}); err != nil {
-			return "", err
-		}
-		data, err := os.ReadFile(f)
-		if err != nil {
-			return "", err
-		}
-		if _, err := h.Write(data); err != nil {
-			return "", err
-		}
-		if _, err := h.Write([]byte{0}); err != nil {
-			return "", err
-		}
+	sort.Strings(paths)
+	h := sha256.New()
+	for _, path := range paths {
+		fmt.Fprintf(h, "%s\x00%s\n", path, files[path])
 	}
-	sum := h.Sum(nil)
-	return fmt.Sprintf("%x", sum), nil
+	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
 // resolveRequirements recursively resolves all plugin requirements
@@ -611,7 +932,7 @@ func fetchExtensions() ([]Plugin, error) {
 	}
 
 	// Fetch from remote pocketstore
-	remoteExtensions, err := fetchRemoteExtensions("https://plugins.pocketstore.io/extensions.json")
+	remoteExtensions, err := fetchRemoteExtensions(extensionsURL)
 	if err != nil {
 		fmt.Printf("Warning: failed to fetch remote extensions: %v\n", err)
 		remoteExtensions = make(map[string]Plugin)
@@ -761,6 +1082,8 @@ func installPlugins() error {
 		return fmt.Errorf("error creating cache directory: %v", err)
 	}
 
+	linked := linkedPluginDirs()
+
 	for i := range plugins {
 		plugin := &plugins[i]
 		pluginVersion := plugin.Version
@@ -773,10 +1096,23 @@ func installPlugins() error {
 
 		// Strip "plugin-" prefix from name for download URL
 		pluginNameForDownload := strings.TrimPrefix(plugin.Name, "plugin-")
-		url := fmt.Sprintf("https://download.pocketstore.io/d/plugins/%s/%s/%s.zip", plugin.Vendor, pluginNameForDownload, pluginVersion)
+		url := fmt.Sprintf("%s/d/plugins/%s/%s/%s.zip", downloadURL, plugin.Vendor, pluginNameForDownload, pluginVersion)
 		zipPath := filepath.Join(cacheDir, fmt.Sprintf("%s-%s-%s.zip", plugin.Vendor, plugin.Name, pluginVersion))
 		destDir := filepath.Join(".plugins", "repos", plugin.Vendor, plugin.Name)
 
+		// Re-downloading a plugin linked with --link would throw away the edits made through the links
+		if linked[filepath.ToSlash(destDir)] && exists(filepath.Join(destDir, "plugin.json")) {
+			if m, err := readManifest(plugin.Vendor, plugin.Name); err == nil {
+				if m.Version != "" && m.Version != plugin.Version {
+					fmt.Printf("  Warning: %s/%s %s is requested, keeping the linked %s\n", plugin.Vendor, plugin.Name, plugin.Version, m.Version)
+					plugin.Version = m.Version
+				}
+				plugin.Revision = m.Revision
+			}
+			fmt.Printf("• %s/%s is linked into storefront/, keeping %s (run plugins unlink to update it)\n", plugin.Vendor, plugin.Name, destDir)
+			continue
+		}
+
 		_ = os.RemoveAll(destDir)
 
 		_, err := DownloadFile(zipPath, url)
@@ -795,7 +1131,12 @@ func installPlugins() error {
 		// Priority:
 		// 1) plugin.json "revision" (already handled below)
 		// 2) .git/HEAD ref inside the extracted destDir (if present)
-		// 3) deterministic SHA1 computed from files as a fallback
+		// 3) SHA-256 digest of the per-file manifest as a fallback
+		files, err := hashDir(destDir)
+		if err != nil {
+			return fmt.Errorf("failed to hash %s: %v", destDir, err)
+		}
+
 		pluginJSONPath := filepath.Join(destDir, "plugin.json")
 		if exists(pluginJSONPath) {
 			if pj, err := readPluginMeta(plugin.Vendor, plugin.Name); err == nil {
@@ -818,10 +1159,18 @@ func installPlugins() error {
 			}
 		}
 		if plugin.Revision == "" {
-			// fallback to deterministic dir hash
-			if rev, err := computeDirSHA1(destDir); err == nil {
-				plugin.Revision = rev
-			}
+			// fallback to deterministic manifest digest
+			plugin.Revision = manifestDigest(files)
+		}
+
+		if err := writeManifest(PluginManifest{
+			Vendor:   plugin.Vendor,
+			Name:     plugin.Name,
+			Version:  plugin.Version,
+			Revision: plugin.Revision,
+			Files:    files,
+		}); err != nil {
+			return fmt.Errorf("failed to write manifest for %s/%s: %v", plugin.Vendor, plugin.Name, err)
 		}
 
 		// One-line success output
@@ -858,6 +1207,7 @@ func mergePluginFiles() error {
 
 		pluginJsonPath := filepath.Join(vendorPath, "plugin.json")
 		if exists(pluginJsonPath) {
+			fmt.Printf("  Warning: %s uses the legacy layout, run `go run bin/plugins.go migrate-layout`\n", vendorPath)
 			prio := readPrio("", vendorEntry.Name())
 			plugins = append(plugins, Plugin{
 				Vendor:   "",
@@ -891,53 +1241,1560 @@ func mergePluginFiles() error {
 		}
 	}
 
-	// Sort plugins by priority descending
-	sort.Slice(plugins, func(i, j int) bool {
+	// Sort plugins by priority descending, so the lowest prio is copied last and wins
+	sort.SliceStable(plugins, func(i, j int) bool {
 		return plugins[i].Prio > plugins[j].Prio
 	})
 
-	// Copy folders for each plugin
-	for _, plugin := range plugins {
-		for _, d := range dirsToCopy {
-			src := filepath.Join(plugin.BasePath, d)
+	// Files custom/ provides are left alone when custom ranks above plugins (see bin/layers.go)
+	var customOwned map[string]bool
+	if layerRank("custom") > layerRank("plugins") {
+		customOwned = customFiles()
+	}
 
-			// public goes to storefront/public, others to storefront/app/<dir>
-			var dst string
-			if d == "public" {
-				dst = filepath.Join("public")
-			} else {
-				dst = filepath.Join("app", d)
+	// Copy exported folders for each plugin, remembering which plugin wrote each file last
+	owners := make(map[string]int)
+	for i, plugin := range plugins {
+		for _, d := range pluginExports(plugin) {
+			target, ok := exportTargets[d]
+			if !ok {
+				fmt.Printf("  Unknown export %q in %s/%s, skipping\n", d, plugin.Vendor, plugin.Name)
+				continue
 			}
 
+			src := filepath.Join(plugin.BasePath, filepath.FromSlash(d))
 			if exists(src) {
-				finalDst := filepath.Join("storefront", dst)
-				if err := copyDir(src, finalDst); err != nil {
+				finalDst := filepath.Join("storefront", filepath.FromSlash(target))
+				written, err := copyDir(src, finalDst, customOwned)
+				if err != nil {
 					fmt.Printf("  Error copying %s: %v\n", d, err)
 				}
+				for _, path := range written {
+					owners[path] = i
+				}
 			}
 		}
 		fmt.Printf("✓ %s/%s (prio: %d)\n", plugin.Vendor, plugin.Name, plugin.Prio)
 	}
+
+	// Record the storefront files each plugin produced for `plugins verify`
+	if err := recordStorefrontFiles(plugins, owners); err != nil {
+		return fmt.Errorf("failed to record storefront files: %v", err)
+	}
+
+	// Copy PocketBase hooks shipped by plugins into .hooks
+	if err := mergePluginHooks(plugins); err != nil {
+		return fmt.Errorf("failed to merge plugin hooks: %v", err)
+	}
+
+	// Compose nuxt modules, runtimeConfig and app head entries of all plugins
+	if err := generateNuxtConfig(plugins); err != nil {
+		return fmt.Errorf("failed to generate %s: %v", nuxtConfig, err)
+	}
+
+	// Add the npm packages plugins need to storefront/package.json
+	if err := mergeNpmDependencies(plugins); err != nil {
+		return fmt.Errorf("failed to merge npm dependencies: %v", err)
+	}
+
+	// Record everything written into storefront/ for drift detection
+	written := make(map[string]string)
+	for path, owner := range owners {
+		written[path] = plugins[owner].Vendor + "/" + plugins[owner].Name
+	}
+	written[nuxtConfig] = "plugins"
+	written[packageJSON] = "plugins"
+	if err := recordBuild(written); err != nil {
+		return fmt.Errorf("failed to record build: %v", err)
+	}
 	return nil
 }
 
-func main() {
-	// Step 1: Merge baseline and custom plugins
-	if err := mergePlugins(); err != nil {
-		fmt.Fprintf(os.Stderr, "FAILED: %v\n", err)
-		os.Exit(1)
+// BuildRecord is the hash of a storefront file as the build wrote it
+type BuildRecord struct {
+	SHA256 string `json:"sha256"`
+	Layer  string `json:"layer"`
+}
+
+// recordBuild stores the hashes of the storefront files just written (path -> layer) in the
+// build ledger, which `go run bin/drift.go` compares the storefront against
+func recordBuild(files map[string]string) error {
+	ledger := make(map[string]BuildRecord)
+	if data, err := os.ReadFile(buildLedger); err == nil {
+		if err := json.Unmarshal(data, &ledger); err != nil {
+			return fmt.Errorf("error parsing %s: %v", buildLedger, err)
+		}
+	}
+	for path, layer := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		ledger[filepath.ToSlash(path)] = BuildRecord{SHA256: fmt.Sprintf("%x", sha256.Sum256(data)), Layer: layer}
 	}
+	if err := os.MkdirAll(filepath.Dir(buildLedger), 0755); err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(ledger, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(buildLedger, out, 0644)
+}
 
-	// Step 2: Install plugins (clean output)
-	if err := installPlugins(); err != nil {
-		fmt.Fprintf(os.Stderr, "FAILED: %v\n", err)
-		os.Exit(1)
+// generateNuxtConfig writes the nuxt.config contributions of all plugins to nuxtConfig,
+// which the baseline nuxt.config.ts imports. Two plugins setting the same runtimeConfig
+// key to different values is reported as a conflict.
+func generateNuxtConfig(plugins []Plugin) error {
+	modules := []string{}
+	seenModules := make(map[string]bool)
+	runtimeConfig := make(map[string]interface{})
+	head := make(map[string]interface{})
+	owners := make(map[string]string) // runtimeConfig key path -> plugin key
+	var conflicts []string
+
+	for _, plugin := range plugins {
+		pj, err := readPluginMeta(plugin.Vendor, plugin.Name)
+		if err != nil || pj.Nuxt == nil {
+			continue
+		}
+		key := plugin.Name
+		if plugin.Vendor != "" {
+			key = plugin.Vendor + "/" + plugin.Name
+		}
+
+		for _, m := range pj.Nuxt.Modules {
+			if !seenModules[m] {
+				seenModules[m] = true
+				modules = append(modules, m)
+			}
+		}
+
+		conflicts = append(conflicts, mergeRuntimeConfig(pj.Nuxt.RuntimeConfig, runtimeConfig, "", key, owners)...)
+
+		// Head entries (script, link, meta, ...) are lists and get concatenated,
+		// single values like title are overwritten
+		for name, value := range pj.Nuxt.Head {
+			if list, ok := value.([]interface{}); ok {
+				existing, _ := head[name].([]interface{})
+				head[name] = append(existing, list...)
+			} else {
+				head[name] = value
+			}
+		}
 	}
 
-	// Step 3: Merge plugin files into storefront
-	fmt.Println("\n==> Merging plugin files into storefront")
-	if err := mergePluginFiles(); err != nil {
-		fmt.Fprintf(os.Stderr, "FAILED: %v\n", err)
-		os.Exit(1)
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		for _, c := range conflicts {
+			fmt.Printf("  runtimeConfig conflict: %s\n", c)
+		}
+		return fmt.Errorf("%d conflicting runtimeConfig keys", len(conflicts))
+	}
+
+	config := map[string]interface{}{
+		"modules":       modules,
+		"runtimeConfig": runtimeConfig,
+		"app":           map[string]interface{}{"head": head},
+	}
+	out, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	content := "// Generated by bin/plugins.go from the plugin.json \"nuxt\" sections. Do not edit.\n" +
+		"export default " + string(out) + "\n"
+	if err := os.MkdirAll(filepath.Dir(nuxtConfig), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(nuxtConfig, []byte(content), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("  nuxt config -> %s (%d modules)\n", nuxtConfig, len(modules))
+	return nil
+}
+
+// mergeRuntimeConfig merges source into target and returns a description of every leaf key
+// already set to a different value by another plugin
+func mergeRuntimeConfig(source, target map[string]interface{}, prefix, owner string, owners map[string]string) []string {
+	var conflicts []string
+	for key, value := range source {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if sourceMap, ok := value.(map[string]interface{}); ok {
+			targetMap, ok := target[key].(map[string]interface{})
+			if !ok {
+				if _, set := target[key]; set {
+					conflicts = append(conflicts, fmt.Sprintf("%s set by %s and %s", path, owners[path], owner))
+					continue
+				}
+				targetMap = make(map[string]interface{})
+				target[key] = targetMap
+				owners[path] = owner
+			}
+			conflicts = append(conflicts, mergeRuntimeConfig(sourceMap, targetMap, path, owner, owners)...)
+			continue
+		}
+		if existing, set := target[key]; set {
+			a, _ := json.Marshal(existing)
+			b, _ := json.Marshal(value)
+			if string(a) != string(b) {
+				conflicts = append(conflicts, fmt.Sprintf("%s set by %s and %s", path, owners[path], owner))
+			}
+			continue
+		}
+		target[key] = value
+		owners[path] = owner
 	}
-}
\ No newline at end of file
+	return conflicts
+}
+
+// recordStorefrontFiles stores the hashes of the storefront files each plugin produced in its
+// manifest. Plugins installed before manifests existed get one from their current files.
+func recordStorefrontFiles(plugins []Plugin, owners map[string]int) error {
+	for i, plugin := range plugins {
+		m, err := readManifest(plugin.Vendor, plugin.Name)
+		if err != nil {
+			files, err := hashDir(plugin.BasePath)
+			if err != nil {
+				return err
+			}
+			m = PluginManifest{Vendor: plugin.Vendor, Name: plugin.Name, Files: files}
+		}
+
+		m.Storefront = make(map[string]string)
+		for path, owner := range owners {
+			if owner != i {
+				continue
+			}
+			sum, err := hashFile(path)
+			if err != nil {
+				return err
+			}
+			m.Storefront[filepath.ToSlash(path)] = sum
+		}
+		if err := writeManifest(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyPlugins compares installed plugins and the storefront files they produced with their
+// manifests and reports added (A), removed (D) and modified (M) files:
+// plugins verify
+func verifyPlugins(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: go run bin/plugins.go verify")
+	}
+
+	manifests, err := filepath.Glob(filepath.Join(manifestRoot, "*", "*.json"))
+	if err != nil {
+		return err
+	}
+	legacy, _ := filepath.Glob(filepath.Join(manifestRoot, "*.json"))
+	manifests = append(manifests, legacy...)
+	if len(manifests) == 0 {
+		return fmt.Errorf("no manifests in %s, run bin/plugins.go first", manifestRoot)
+	}
+	sort.Strings(manifests)
+
+	// Storefront files custom/ overrides on purpose are not drift
+	var customOwned map[string]bool
+	if layerRank("custom") > layerRank("plugins") {
+		customOwned = customFiles()
+	}
+
+	changed := 0
+	for _, path := range manifests {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var m PluginManifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			return fmt.Errorf("error parsing %s: %v", path, err)
+		}
+		key := m.Name
+		if m.Vendor != "" {
+			key = m.Vendor + "/" + m.Name
+		}
+
+		var report []string
+		dir := filepath.Join(pluginRoot, m.Vendor, m.Name)
+		current, err := hashDir(dir)
+		if err != nil {
+			report = append(report, fmt.Sprintf("  D %s (plugin directory missing)", dir))
+			current = map[string]string{}
+		}
+		for file, sum := range m.Files {
+			if now, ok := current[file]; !ok {
+				report = append(report, "  D "+file)
+			} else if now != sum {
+				report = append(report, "  M "+file)
+			}
+		}
+		for file := range current {
+			if _, ok := m.Files[file]; !ok {
+				report = append(report, "  A "+file)
+			}
+		}
+		for file, sum := range m.Storefront {
+			if customOwned[filepath.FromSlash(file)] {
+				continue
+			}
+			now, err := hashFile(filepath.FromSlash(file))
+			if err != nil {
+				report = append(report, "  D "+file)
+			} else if now != sum {
+				report = append(report, "  M "+file)
+			}
+		}
+
+		if len(report) == 0 {
+			fmt.Printf("✓ %s\n", key)
+			continue
+		}
+		changed++
+		sort.Strings(report)
+		fmt.Printf("✗ %s\n", key)
+		for _, line := range report {
+			fmt.Println(line)
+		}
+	}
+
+	if changed > 0 {
+		return fmt.Errorf("%d plugin(s) differ from their manifest", changed)
+	}
+	return nil
+}
+
+// pluginExports returns the exports declared in the plugin.json of plugin,
+// falling back to defaultExports when none are declared
+func pluginExports(plugin Plugin) []string {
+	pj, err := readPluginMeta(plugin.Vendor, plugin.Name)
+	if err != nil || len(pj.Exports) == 0 {
+		return defaultExports
+	}
+	return pj.Exports
+}
+
+// pluginHookName returns the plugin-prefixed filename a hook is copied to in .hooks,
+// e.g. "pocketstore-io/plugin-checkout" + "order.pb.js" -> "pocketstore-io.plugin-checkout.order.pb.js"
+func pluginHookName(plugin Plugin, file string) string {
+	if plugin.Vendor == "" {
+		return plugin.Name + "." + file
+	}
+	return plugin.Vendor + "." + plugin.Name + "." + file
+}
+
+// readHooksManifest reads the hook manifest written by the previous run.
+// The manifest maps a filename in .hooks to the plugin key that shipped it.
+func readHooksManifest(path string) (map[string]string, error) {
+	manifest := make(map[string]string)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return manifest, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// mergePluginHooks copies hooks/*.pb.js of every plugin into .hooks with a plugin-prefixed
+// filename, removes hooks of plugins that are no longer installed and refuses to overwrite
+// hand-written hooks or hooks of another plugin.
+func mergePluginHooks(plugins []Plugin) error {
+	previous, err := readHooksManifest(hooksManifest)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", hooksManifest, err)
+	}
+
+	current := make(map[string]string)
+	for _, plugin := range plugins {
+		matches, err := filepath.Glob(filepath.Join(plugin.BasePath, "hooks", "*.pb.js"))
+		if err != nil {
+			return err
+		}
+		sort.Strings(matches)
+
+		key := plugin.Name
+		if plugin.Vendor != "" {
+			key = plugin.Vendor + "/" + plugin.Name
+		}
+
+		for _, src := range matches {
+			target := pluginHookName(plugin, filepath.Base(src))
+			if owner, clash := current[target]; clash {
+				fmt.Printf("  Hook clash: %s from %s already provided by %s, skipping\n", target, key, owner)
+				continue
+			}
+			dst := filepath.Join(hooksRoot, target)
+			if _, tracked := previous[target]; !tracked && exists(dst) {
+				fmt.Printf("  Hook clash: %s from %s would overwrite a hand-written hook, skipping\n", target, key)
+				continue
+			}
+			if err := copyFile(src, dst); err != nil {
+				fmt.Printf("  Error copying hook %s: %v\n", src, err)
+				// Keep the hook of the previous run instead of removing it as stale
+				if owner, tracked := previous[target]; tracked {
+					current[target] = owner
+				}
+				continue
+			}
+			current[target] = key
+			fmt.Printf("  hook %s -> %s\n", key, dst)
+		}
+	}
+
+	// Remove hooks whose plugin (or hook file) disappeared since the previous run
+	for target, owner := range previous {
+		if _, ok := current[target]; ok {
+			continue
+		}
+		dst := filepath.Join(hooksRoot, target)
+		if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("  Error removing stale hook %s: %v\n", dst, err)
+			continue
+		}
+		fmt.Printf("  removed stale hook %s (was %s)\n", dst, owner)
+	}
+
+	out, err := json.MarshalIndent(current, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling hook manifest: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(hooksManifest), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(hooksManifest, out, 0644)
+}
+
+// semver is a major.minor.patch version; pre-release and build suffixes are ignored
+type semver [3]int
+
+func (v semver) less(o semver) bool {
+	for i := 0; i < 3; i++ {
+		if v[i] != o[i] {
+			return v[i] < o[i]
+		}
+	}
+	return false
+}
+
+func (v semver) String() string {
+	return fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+}
+
+// npmRange is a semver range reduced to a single interval
+type npmRange struct {
+	min, max         semver
+	hasMin, hasMax   bool
+	minIncl, maxIncl bool
+}
+
+// parseVersion parses "1", "1.2", "1.2.3", "v1.2.3" or "1.2.x" and returns
+// the version together with the number of components given
+func parseVersion(s string) (semver, int, error) {
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	var v semver
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return v, 0, fmt.Errorf("invalid version %q", s)
+	}
+	n := 0
+	for i, p := range parts {
+		if p == "x" || p == "X" || p == "*" {
+			break
+		}
+		num, err := strconv.Atoi(p)
+		if err != nil || num < 0 {
+			return v, 0, fmt.Errorf("invalid version %q", s)
+		}
+		v[i] = num
+		n++
+	}
+	return v, n, nil
+}
+
+// parseNpmRange parses the subset of npm semver ranges used in practice:
+// "*", exact and partial versions, ^, ~ and >, >=, <, <= comparators joined by spaces
+func parseNpmRange(spec string) (npmRange, error) {
+	var r npmRange
+	spec = strings.TrimSpace(spec)
+	if strings.Contains(spec, "||") || strings.Contains(spec, " - ") {
+		return r, fmt.Errorf("unsupported range %q", spec)
+	}
+	for _, c := range strings.Fields(spec) {
+		if c == "*" || c == "x" || c == "latest" {
+			continue
+		}
+		op := ""
+		for _, prefix := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+			if strings.HasPrefix(c, prefix) {
+				op = prefix
+				break
+			}
+		}
+		v, n, err := parseVersion(strings.TrimPrefix(c, op))
+		if err != nil {
+			return r, err
+		}
+
+		// upper is the exclusive upper bound implied by a partial version
+		upper := func(v semver, n int) semver {
+			switch n {
+			case 0:
+				return semver{1 << 30}
+			case 1:
+				return semver{v[0] + 1}
+			case 2:
+				return semver{v[0], v[1] + 1}
+			}
+			return semver{v[0], v[1], v[2] + 1}
+		}
+
+		switch op {
+		case "^":
+			r.lower(v, true)
+			switch {
+			case v[0] > 0 || n == 1:
+				r.upper(semver{v[0] + 1}, false)
+			case v[1] > 0 || n == 2:
+				r.upper(semver{0, v[1] + 1}, false)
+			default:
+				r.upper(semver{0, 0, v[2] + 1}, false)
+			}
+		case "~":
+			r.lower(v, true)
+			if n == 1 {
+				r.upper(semver{v[0] + 1}, false)
+			} else {
+				r.upper(semver{v[0], v[1] + 1}, false)
+			}
+		case ">=":
+			r.lower(v, true)
+		case ">":
+			if n < 3 {
+				r.lower(upper(v, n), true)
+			} else {
+				r.lower(v, false)
+			}
+		case "<=":
+			if n < 3 {
+				r.upper(upper(v, n), false)
+			} else {
+				r.upper(v, true)
+			}
+		case "<":
+			r.upper(v, false)
+		default:
+			r.lower(v, true)
+			if n < 3 {
+				r.upper(upper(v, n), false)
+			} else {
+				r.upper(v, true)
+			}
+		}
+	}
+	return r, nil
+}
+
+// lower raises the lower bound of r to v if v is stricter
+func (r *npmRange) lower(v semver, incl bool) {
+	if !r.hasMin || r.min.less(v) || (r.min == v && !incl) {
+		r.min, r.minIncl, r.hasMin = v, incl, true
+	}
+}
+
+// upper lowers the upper bound of r to v if v is stricter
+func (r *npmRange) upper(v semver, incl bool) {
+	if !r.hasMax || v.less(r.max) || (r.max == v && !incl) {
+		r.max, r.maxIncl, r.hasMax = v, incl, true
+	}
+}
+
+// intersect returns the range satisfying both r and o
+func (r npmRange) intersect(o npmRange) npmRange {
+	if o.hasMin {
+		r.lower(o.min, o.minIncl)
+	}
+	if o.hasMax {
+		r.upper(o.max, o.maxIncl)
+	}
+	return r
+}
+
+// empty reports whether no version satisfies r
+func (r npmRange) empty() bool {
+	if !r.hasMin || !r.hasMax {
+		return false
+	}
+	if r.max.less(r.min) {
+		return true
+	}
+	return r.min == r.max && !(r.minIncl && r.maxIncl)
+}
+
+// String formats r as an npm range
+func (r npmRange) String() string {
+	if r.hasMin && r.hasMax && r.min == r.max {
+		return r.min.String()
+	}
+	var parts []string
+	if r.hasMin {
+		op := ">"
+		if r.minIncl {
+			op = ">="
+		}
+		parts = append(parts, op+r.min.String())
+	}
+	if r.hasMax {
+		op := "<"
+		if r.maxIncl {
+			op = "<="
+		}
+		parts = append(parts, op+r.max.String())
+	}
+	if len(parts) == 0 {
+		return "*"
+	}
+	return strings.Join(parts, " ")
+}
+
+// npmRequirement is the merged requirement of all plugins on one npm package
+type npmRequirement struct {
+	spec    string
+	rng     npmRange
+	semver  bool
+	plugins []string
+}
+
+// mergeNpmDependencies merges the "npmDependencies" of all plugins into storefront/package.json.
+// Ranges of the same package are intersected; packages added by a previous run whose
+// plugins are gone are removed again.
+func mergeNpmDependencies(plugins []Plugin) error {
+	requirements := make(map[string]*npmRequirement)
+	var conflicts []string
+
+	for _, plugin := range plugins {
+		pj, err := readPluginMeta(plugin.Vendor, plugin.Name)
+		if err != nil {
+			continue
+		}
+		key := plugin.Name
+		if plugin.Vendor != "" {
+			key = plugin.Vendor + "/" + plugin.Name
+		}
+
+		for pkg, spec := range pj.NpmDependencies {
+			rng, rangeErr := parseNpmRange(spec)
+			req, ok := requirements[pkg]
+			if !ok {
+				requirements[pkg] = &npmRequirement{spec: spec, rng: rng, semver: rangeErr == nil, plugins: []string{key}}
+				continue
+			}
+			req.plugins = append(req.plugins, key)
+			if req.spec == spec {
+				continue
+			}
+			if !req.semver || rangeErr != nil {
+				conflicts = append(conflicts, fmt.Sprintf("%s: %q and %q cannot be combined (%s)", pkg, req.spec, spec, strings.Join(req.plugins, ", ")))
+				continue
+			}
+			merged := req.rng.intersect(rng)
+			if merged.empty() {
+				conflicts = append(conflicts, fmt.Sprintf("%s: %q and %q do not overlap (%s)", pkg, req.spec, spec, strings.Join(req.plugins, ", ")))
+				continue
+			}
+			req.rng = merged
+			req.spec = merged.String()
+		}
+	}
+
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		for _, c := range conflicts {
+			fmt.Printf("  npm dependency conflict: %s\n", c)
+		}
+		return fmt.Errorf("%d conflicting npm dependencies", len(conflicts))
+	}
+
+	previous := make(map[string]string)
+	if data, err := os.ReadFile(npmManifest); err == nil {
+		if err := json.Unmarshal(data, &previous); err != nil {
+			return fmt.Errorf("error parsing %s: %v", npmManifest, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if !exists(packageJSON) {
+		fmt.Printf("  %s not found, skipping npm dependencies\n", packageJSON)
+		return nil
+	}
+	keys, fields, err := readOrderedJSON(packageJSON)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", packageJSON, err)
+	}
+	dependencies := make(map[string]string)
+	if raw, ok := fields["dependencies"]; ok {
+		if err := json.Unmarshal(raw, &dependencies); err != nil {
+			return fmt.Errorf("error parsing dependencies in %s: %v", packageJSON, err)
+		}
+	} else {
+		keys = append(keys, "dependencies")
+	}
+
+	// Remove what we added last time unless the storefront itself changed it since
+	for pkg, spec := range previous {
+		if _, ok := requirements[pkg]; ok {
+			continue
+		}
+		if dependencies[pkg] == spec {
+			delete(dependencies, pkg)
+			fmt.Printf("  npm - %s (no longer required)\n", pkg)
+		}
+	}
+
+	current := make(map[string]string)
+	for pkg, req := range requirements {
+		if existing, ok := dependencies[pkg]; ok && previous[pkg] != existing {
+			// Declared by the baseline storefront itself: keep it, only check compatibility
+			if rng, err := parseNpmRange(existing); err == nil && req.semver && rng.intersect(req.rng).empty() {
+				fmt.Printf("  Warning: %s %q required by %s does not overlap storefront's %q\n", pkg, req.spec, strings.Join(req.plugins, ", "), existing)
+			}
+			continue
+		}
+		dependencies[pkg] = req.spec
+		current[pkg] = req.spec
+		fmt.Printf("  npm + %s@%s (%s)\n", pkg, req.spec, strings.Join(req.plugins, ", "))
+	}
+
+	raw, err := marshalUnescaped(dependencies, "")
+	if err != nil {
+		return err
+	}
+	fields["dependencies"] = raw
+	if err := writeOrderedJSON(packageJSON, keys, fields); err != nil {
+		return fmt.Errorf("error writing %s: %v", packageJSON, err)
+	}
+
+	out, err := marshalUnescaped(current, "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(npmManifest, out, 0644)
+}
+
+// marshalUnescaped marshals v like json.MarshalIndent but keeps <, > and & readable,
+// which npm ranges like ">=1.0.0 <2.0.0" rely on
+func marshalUnescaped(v interface{}, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// readOrderedJSON reads a JSON object and returns its top-level keys in file order
+// together with their raw values, so it can be written back without reordering
+func readOrderedJSON(path string) ([]string, map[string]json.RawMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, nil, fmt.Errorf("expected a JSON object")
+	}
+	var keys []string
+	fields := make(map[string]json.RawMessage)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key, _ := tok.(string)
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, key)
+		fields[key] = raw
+	}
+	return keys, fields, nil
+}
+
+// writeOrderedJSON writes the fields as an indented JSON object in the given key order
+func writeOrderedJSON(path string, keys []string, fields map[string]json.RawMessage) error {
+	out, err := encodeOrderedJSON(keys, fields)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, out, 0644)
+}
+
+// encodeOrderedJSON returns the fields as an indented JSON object in the given key order.
+// Values are copied as they are, so nothing is HTML escaped.
+func encodeOrderedJSON(keys []string, fields map[string]json.RawMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("{\n")
+	for i, key := range keys {
+		name, _ := json.Marshal(key)
+		buf.WriteString("  " + string(name) + ": ")
+		if err := json.Indent(&buf, fields[key], "  ", "  "); err != nil {
+			return nil, err
+		}
+		if i < len(keys)-1 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes(), nil
+}
+
+// pluginScaffold is the plugin.json written for new plugins; unlike PluginJson it keeps
+// empty fields so authors see every field they can fill in
+type pluginScaffold struct {
+	Name         string   `json:"name"`
+	Vendor       string   `json:"vendor"`
+	Version      string   `json:"version"`
+	Prio         int      `json:"prio"`
+	Requirements []string `json:"requirements"`
+	Exports      []string `json:"exports"`
+}
+
+// scaffoldLanguages returns the locale codes configured in custom/pocketstore.json,
+// falling back to en and de
+func scaffoldLanguages() []string {
+	var config struct {
+		Language struct {
+			Locales []struct {
+				Code string `json:"code"`
+			} `json:"locales"`
+		} `json:"language"`
+	}
+	data, err := os.ReadFile("custom/pocketstore.json")
+	if err == nil && json.Unmarshal(data, &config) == nil && len(config.Language.Locales) > 0 {
+		var langs []string
+		for _, l := range config.Language.Locales {
+			langs = append(langs, l.Code)
+		}
+		return langs
+	}
+	return []string{"en", "de"}
+}
+
+// newPlugin creates a plugin skeleton in .plugins/repos/<vendor>/<plugin-name>:
+// plugins new [--prio N] vendor/name
+func newPlugin(args []string) error {
+	fs := flag.NewFlagSet("new", flag.ExitOnError)
+	prio := fs.Int("prio", 0, "plugin priority written to plugin.json")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: go run bin/plugins.go new [--prio N] vendor/name")
+	}
+
+	vendor, name, ok := parsePluginURL(fs.Arg(0))
+	if !ok {
+		return fmt.Errorf("invalid plugin %q, expected vendor/name", fs.Arg(0))
+	}
+	dir := filepath.Join(pluginRoot, vendor, name)
+	if exists(dir) {
+		return fmt.Errorf("%s already exists", dir)
+	}
+
+	// "plugin-image-slider" -> slug "image-slider", component "ImageSlider"
+	slug := strings.TrimPrefix(name, "plugin-")
+	component := ""
+	for _, part := range strings.Split(slug, "-") {
+		if part != "" {
+			component += strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+
+	meta, err := json.MarshalIndent(pluginScaffold{
+		Name:         name,
+		Vendor:       vendor,
+		Version:      "0.0.1",
+		Prio:         *prio,
+		Requirements: []string{},
+		Exports:      []string{"pages", "components"},
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	files := map[string]string{
+		"plugin.json": string(meta) + "\n",
+		"schema.json": "[]\n",
+		filepath.Join("pages", slug+".vue"): fmt.Sprintf(`<template>
+  <div>
+    <%s />
+  </div>
+</template>
+`, component),
+		filepath.Join("components", component+".vue"): fmt.Sprintf(`<template>
+  <div>{{ $t('%s.title') }}</div>
+</template>
+`, slug),
+		"README.md": fmt.Sprintf(`# %s/%s
+
+## Install
+
+Add the plugin to custom/plugins.json:
+
+`+"```json"+`
+{ "vendor": "%s", "name": "%s", "version": "latest" }
+`+"```"+`
+
+## Structure
+
+- plugin.json: priority, version, requirements and exports
+- pages/, components/: copied into storefront/app
+- translations/: merged into storefront/i18n/locales
+- schema.json: PocketBase collections merged by bin/schema.go
+`, vendor, name, vendor, name),
+	}
+	for _, lang := range scaffoldLanguages() {
+		files[filepath.Join("translations", lang+".json")] = fmt.Sprintf("{\n  %q: {\n    \"title\": %q\n  }\n}\n", slug, component)
+	}
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		target := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, []byte(files[path]), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("  created %s\n", target)
+	}
+	fmt.Printf("✓ %s/%s\n", vendor, name)
+	return nil
+}
+
+// volatileFiles are never packed into plugin zips
+var volatileFiles = map[string]bool{
+	".git":         true,
+	".DS_Store":    true,
+	"Thumbs.db":    true,
+	"node_modules": true,
+	".idea":        true,
+	".vscode":      true,
+}
+
+// validatePluginJson checks the fields install and merge rely on
+func validatePluginJson(pj PluginJson) []string {
+	var problems []string
+	if pj.Prio < 0 {
+		problems = append(problems, "prio must not be negative")
+	}
+	for _, req := range pj.Requirements {
+		if _, _, ok := parsePluginURL(req); !ok {
+			problems = append(problems, fmt.Sprintf("invalid requirement %q", req))
+		}
+	}
+	for _, e := range pj.Exports {
+		if _, ok := exportTargets[e]; !ok {
+			problems = append(problems, fmt.Sprintf("unknown export %q", e))
+		}
+	}
+	return problems
+}
+
+// packPlugin builds a zip in the layout installPlugins downloads and Unzip extracts:
+// plugins pack [--version V] [--out DIR] [--sign-key KEY.pem] <dir>
+// The zip is written to DIR/<vendor>/<name>/<version>.zip (name without "plugin-")
+// together with a .sha256 checksum and, with --sign-key, an ed25519 .sig signature.
+func packPlugin(args []string) error {
+	fs := flag.NewFlagSet("pack", flag.ExitOnError)
+	version := fs.String("version", "", "version stamped into plugin.json (default: version from plugin.json)")
+	outDir := fs.String("out", ".plugins/dist", "output directory")
+	signKey := fs.String("sign-key", "", "PEM encoded ed25519 private key (PKCS#8) to sign the zip with")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: go run bin/plugins.go pack [--version V] [--out DIR] [--sign-key KEY.pem] <dir>")
+	}
+	dir := filepath.Clean(fs.Arg(0))
+
+	data, err := os.ReadFile(filepath.Join(dir, "plugin.json"))
+	if err != nil {
+		return err
+	}
+	var pj PluginJson
+	if err := json.Unmarshal(data, &pj); err != nil {
+		return fmt.Errorf("invalid plugin.json: %v", err)
+	}
+	// Keep unknown fields, their order and their text intact when stamping the version
+	keys, fields, err := readOrderedJSON(filepath.Join(dir, "plugin.json"))
+	if err != nil {
+		return fmt.Errorf("invalid plugin.json: %v", err)
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("invalid plugin.json: %v", err)
+	}
+
+	if *version != "" {
+		pj.Version = *version
+	}
+	if pj.Version == "" {
+		return fmt.Errorf("no version in plugin.json, pass --version")
+	}
+	if problems := validatePluginJson(pj); len(problems) > 0 {
+		for _, p := range problems {
+			fmt.Printf("  plugin.json: %s\n", p)
+		}
+		return fmt.Errorf("plugin.json of %s is invalid", dir)
+	}
+	stampedVersion, err := marshalUnescaped(pj.Version, "")
+	if err != nil {
+		return err
+	}
+	if _, ok := fields["version"]; !ok {
+		keys = append(keys, "version")
+	}
+	fields["version"] = stampedVersion
+
+	// vendor and name come from plugin.json or the .plugins/repos/<vendor>/<name> layout
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	vendor, _ := raw["vendor"].(string)
+	name, _ := raw["name"].(string)
+	if vendor == "" {
+		vendor = filepath.Base(filepath.Dir(abs))
+	}
+	if name == "" {
+		name = filepath.Base(abs)
+	}
+	shortName := strings.TrimPrefix(name, "plugin-")
+
+	stamped, err := encodeOrderedJSON(keys, fields)
+	if err != nil {
+		return err
+	}
+
+	var files []string
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if volatileFiles[info.Name()] {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	target := filepath.Join(*outDir, vendor, shortName, pj.Version+".zip")
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(out)
+
+	// Unzip strips the first path component of the first entry, so every entry lives
+	// below one top-level folder and that folder is written first
+	top := shortName + "-" + pj.Version + "/"
+	if _, err := zw.Create(top); err != nil {
+		out.Close()
+		return err
+	}
+	for _, f := range files {
+		rel, _ := filepath.Rel(dir, f)
+		w, err := zw.Create(top + filepath.ToSlash(rel))
+		if err != nil {
+			out.Close()
+			return err
+		}
+		if rel == "plugin.json" {
+			_, err = w.Write(stamped)
+		} else {
+			var content []byte
+			content, err = os.ReadFile(f)
+			if err == nil {
+				_, err = w.Write(content)
+			}
+		}
+		if err != nil {
+			out.Close()
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	zipData, err := os.ReadFile(target)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(zipData)
+	checksum := fmt.Sprintf("%x  %s\n", sum, filepath.Base(target))
+	if err := os.WriteFile(target+".sha256", []byte(checksum), 0644); err != nil {
+		return err
+	}
+
+	if *signKey != "" {
+		keyData, err := os.ReadFile(*signKey)
+		if err != nil {
+			return err
+		}
+		block, _ := pem.Decode(keyData)
+		if block == nil {
+			return fmt.Errorf("%s is not PEM encoded", *signKey)
+		}
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("error parsing %s: %v", *signKey, err)
+		}
+		edKey, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return fmt.Errorf("%s is not an ed25519 key", *signKey)
+		}
+		sig := base64.StdEncoding.EncodeToString(ed25519.Sign(edKey, zipData))
+		if err := os.WriteFile(target+".sig", []byte(sig+"\n"), 0644); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("✓ %s/%s (version=%s) -> %s (%d files)\n", vendor, name, pj.Version, target, len(files))
+	return nil
+}
+
+// GraphNode is a plugin or plugin list in the dependency graph
+type GraphNode struct {
+	ID       string `json:"id"`
+	Kind     string `json:"kind"` // "source", "plugin" or "missing" (required but not installed)
+	Source   string `json:"source,omitempty"`
+	Prio     int    `json:"prio"`
+	Version  string `json:"version,omitempty"`
+	Revision string `json:"revision,omitempty"`
+}
+
+// GraphEdge connects a source to the plugins it declares, or a plugin to its requirements
+type GraphEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Label string `json:"label"`
+}
+
+// Graph is the resolved plugin dependency graph
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// sourceLabels names the plugin lists a root plugin can come from
+var sourceLabels = map[string]string{
+	"baseline":   "baseline/plugins.json",
+	"custom":     "custom/plugins.json",
+	"storefront": "storefront/plugins.json",
+	"extensions": "extensions",
+}
+
+// buildGraph builds the dependency graph from installed.json and the requirements
+// in the plugin.json of every downloaded plugin
+func buildGraph(installed []Plugin) Graph {
+	var g Graph
+	nodes := make(map[string]bool)
+	edges := make(map[GraphEdge]bool)
+
+	addEdge := func(e GraphEdge) {
+		if !edges[e] {
+			edges[e] = true
+			g.Edges = append(g.Edges, e)
+		}
+	}
+
+	for _, p := range installed {
+		key := p.Vendor + "/" + p.Name
+		prio := p.Prio
+		meta, metaErr := readPluginMeta(p.Vendor, p.Name)
+		if metaErr == nil && prio == 0 {
+			prio = meta.Prio
+		}
+		nodes[key] = true
+		g.Nodes = append(g.Nodes, GraphNode{
+			ID:       key,
+			Kind:     "plugin",
+			Source:   p.Source,
+			Prio:     prio,
+			Version:  p.Version,
+			Revision: p.Revision,
+		})
+
+		if label, ok := sourceLabels[p.Source]; ok {
+			if !nodes[label] {
+				nodes[label] = true
+				g.Nodes = append(g.Nodes, GraphNode{ID: label, Kind: "source"})
+			}
+			edgeLabel := "declares"
+			if p.Source == "extensions" {
+				edgeLabel = "installs"
+			}
+			addEdge(GraphEdge{From: label, To: key, Label: edgeLabel})
+		} else if p.Source != "" {
+			addEdge(GraphEdge{From: p.Source, To: key, Label: "requires"})
+		}
+
+		if metaErr != nil {
+			continue
+		}
+		for _, req := range meta.Requirements {
+			vendor, name, ok := parsePluginURL(req)
+			if ok {
+				addEdge(GraphEdge{From: key, To: vendor + "/" + name, Label: "requires"})
+			}
+		}
+	}
+
+	// Requirements that are not in installed.json still get a node
+	for _, e := range g.Edges {
+		if !nodes[e.To] {
+			nodes[e.To] = true
+			g.Nodes = append(g.Nodes, GraphNode{ID: e.To, Kind: "missing"})
+		}
+	}
+
+	// Sources first, then plugins, then missing requirements, each sorted by ID
+	kindRank := map[string]int{"source": 0, "plugin": 1, "missing": 2}
+	sort.Slice(g.Nodes, func(i, j int) bool {
+		if g.Nodes[i].Kind != g.Nodes[j].Kind {
+			return kindRank[g.Nodes[i].Kind] < kindRank[g.Nodes[j].Kind]
+		}
+		return g.Nodes[i].ID < g.Nodes[j].ID
+	})
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		return g.Edges[i].To < g.Edges[j].To
+	})
+	return g
+}
+
+// nodeLabel returns the multi-line label of a node for dot and mermaid output
+func nodeLabel(n GraphNode) []string {
+	lines := []string{n.ID}
+	switch n.Kind {
+	case "source":
+		return lines
+	case "missing":
+		return append(lines, "not installed")
+	}
+	lines = append(lines, fmt.Sprintf("version=%s prio=%d", n.Version, n.Prio))
+	if n.Revision != "" {
+		rev := n.Revision
+		if len(rev) > 12 {
+			rev = rev[:12]
+		}
+		lines = append(lines, "revision="+rev)
+	}
+	return lines
+}
+
+// formatDot renders g as a graphviz digraph
+func formatDot(g Graph) string {
+	var b strings.Builder
+	b.WriteString("digraph plugins {\n  rankdir=LR;\n  node [shape=box];\n")
+	for _, n := range g.Nodes {
+		label := strings.ReplaceAll(strings.Join(nodeLabel(n), "\n"), `"`, `\"`)
+		label = strings.ReplaceAll(label, "\n", `\n`)
+		switch n.Kind {
+		case "source":
+			fmt.Fprintf(&b, "  %q [label=\"%s\", shape=folder];\n", n.ID, label)
+			continue
+		case "missing":
+			fmt.Fprintf(&b, "  %q [label=\"%s\", style=dashed];\n", n.ID, label)
+			continue
+		}
+		fmt.Fprintf(&b, "  %q [label=\"%s\", source=%q, prio=%d, version=%q, revision=%q];\n", n.ID, label, n.Source, n.Prio, n.Version, n.Revision)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %q -> %q [label=%q];\n", e.From, e.To, e.Label)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// formatMermaid renders g as a mermaid flowchart
+func formatMermaid(g Graph) string {
+	ids := make(map[string]string)
+	for i, n := range g.Nodes {
+		ids[n.ID] = fmt.Sprintf("n%d", i)
+	}
+
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	for _, n := range g.Nodes {
+		label := strings.ReplaceAll(strings.Join(nodeLabel(n), "<br/>"), `"`, "#quot;")
+		if n.Kind == "source" {
+			fmt.Fprintf(&b, "  %s[(\"%s\")]\n", ids[n.ID], label)
+		} else {
+			fmt.Fprintf(&b, "  %s[\"%s\"]\n", ids[n.ID], label)
+		}
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %s -->|%s| %s\n", ids[e.From], e.Label, ids[e.To])
+	}
+	return b.String()
+}
+
+// graphPlugins prints the resolved dependency graph:
+// plugins graph [--format dot|mermaid|json] [--out FILE]
+func graphPlugins(args []string) error {
+	fs := flag.NewFlagSet("graph", flag.ExitOnError)
+	format := fs.String("format", "dot", "output format: dot, mermaid or json")
+	outFile := fs.String("out", "", "write to file instead of stdout")
+	fs.Parse(args)
+
+	installed, err := readPluginsFromFile(".plugins/installed.json")
+	if err != nil {
+		return fmt.Errorf("error reading .plugins/installed.json (run bin/plugins.go first): %v", err)
+	}
+	g := buildGraph(installed)
+
+	var out string
+	switch *format {
+	case "dot":
+		out = formatDot(g)
+	case "mermaid":
+		out = formatMermaid(g)
+	case "json":
+		data, err := json.MarshalIndent(g, "", "  ")
+		if err != nil {
+			return err
+		}
+		out = string(data) + "\n"
+	default:
+		return fmt.Errorf("unknown format %q (available: dot, mermaid, json)", *format)
+	}
+
+	if *outFile == "" {
+		fmt.Print(out)
+		return nil
+	}
+	return os.WriteFile(*outFile, []byte(out), 0644)
+}
+
+// migrateLayout moves plugins in the legacy .plugins/repos/<name> layout to
+// .plugins/repos/<vendor>/<name> and updates installed.json and manifests accordingly:
+// plugins migrate-layout [--dry-run] [--vendor VENDOR]
+// The vendor is taken from plugin.json, then from installed.json, then from --vendor.
+// Plugins whose vendor cannot be determined unambiguously are reported and left alone.
+func migrateLayout(args []string) error {
+	fs := flag.NewFlagSet("migrate-layout", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only print what would be done")
+	fallbackVendor := fs.String("vendor", "", "vendor for legacy plugins that neither plugin.json nor installed.json name")
+	fs.Parse(args)
+
+	entries, err := os.ReadDir(pluginRoot)
+	if err != nil {
+		return fmt.Errorf("failed to read plugin root: %v", err)
+	}
+
+	installedPath := ".plugins/installed.json"
+	installed, err := readPluginsFromFile(installedPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading %s: %v", installedPath, err)
+	}
+
+	// Vendors installed.json knows for each plugin name
+	vendorsByName := make(map[string]map[string]bool)
+	for _, p := range installed {
+		if p.Vendor == "" {
+			continue
+		}
+		if vendorsByName[p.Name] == nil {
+			vendorsByName[p.Name] = make(map[string]bool)
+		}
+		vendorsByName[p.Name][p.Vendor] = true
+	}
+
+	var migrated, ambiguous int
+	for _, entry := range entries {
+		if !entry.IsDir() || !exists(filepath.Join(pluginRoot, entry.Name(), "plugin.json")) {
+			continue
+		}
+		name := entry.Name()
+
+		pj, err := readPluginMeta("", name)
+		if err != nil {
+			fmt.Printf("? %s: cannot read plugin.json: %v\n", name, err)
+			ambiguous++
+			continue
+		}
+		vendor := pj.Vendor
+		if vendor == "" {
+			var candidates []string
+			for v := range vendorsByName[name] {
+				candidates = append(candidates, v)
+			}
+			sort.Strings(candidates)
+			switch len(candidates) {
+			case 0:
+				vendor = *fallbackVendor
+			case 1:
+				vendor = candidates[0]
+			default:
+				fmt.Printf("? %s: installed.json lists several vendors (%s), set \"vendor\" in plugin.json\n", name, strings.Join(candidates, ", "))
+				ambiguous++
+				continue
+			}
+		}
+		if vendor == "" {
+			fmt.Printf("? %s: unknown vendor, set \"vendor\" in plugin.json or pass --vendor\n", name)
+			ambiguous++
+			continue
+		}
+
+		src := filepath.Join(pluginRoot, name)
+		dst := filepath.Join(pluginRoot, vendor, name)
+		if exists(dst) {
+			fmt.Printf("? %s: %s already exists, remove one of them\n", name, dst)
+			ambiguous++
+			continue
+		}
+
+		fmt.Printf("→ %s -> %s\n", src, dst)
+		migrated++
+		for i := range installed {
+			if installed[i].Vendor == "" && installed[i].Name == name {
+				installed[i].Vendor = vendor
+				fmt.Printf("  installed.json: %s -> %s/%s\n", name, vendor, name)
+			}
+		}
+		if *dryRun {
+			continue
+		}
+
+		// Move via a temporary name, the vendor directory may not exist yet
+		tmp := src + ".migrating"
+		if err := os.Rename(src, tmp); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, dst); err != nil {
+			return err
+		}
+
+		if m, err := readManifest("", name); err == nil {
+			m.Vendor = vendor
+			if err := writeManifest(m); err != nil {
+				return err
+			}
+			_ = os.Remove(manifestPath("", name))
+		}
+	}
+
+	if migrated > 0 && !*dryRun && installed != nil {
+		out, err := json.MarshalIndent(installed, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(installedPath, out, 0644); err != nil {
+			return fmt.Errorf("error writing %s: %v", installedPath, err)
+		}
+	}
+
+	verb := "migrated"
+	if *dryRun {
+		verb = "would be migrated"
+	}
+	fmt.Printf("%d legacy plugin(s) %s, %d need attention\n", migrated, verb, ambiguous)
+	if ambiguous > 0 {
+		return fmt.Errorf("%d legacy plugin(s) could not be migrated", ambiguous)
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	args := flag.Args()
+
+	// Subcommands; without arguments the full install pipeline runs
+	if len(args) > 0 {
+		var err error
+		switch args[0] {
+		case "new":
+			err = newPlugin(args[1:])
+		case "pack":
+			err = packPlugin(args[1:])
+		case "graph":
+			err = graphPlugins(args[1:])
+		case "verify":
+			err = verifyPlugins(args[1:])
+		case "migrate-layout":
+			err = migrateLayout(args[1:])
+		case "unlink":
+			err = unlinkStorefront(args[1:])
+		default:
+			err = fmt.Errorf("unknown command %q (available: new, pack, graph, verify, migrate-layout, unlink)", args[0])
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "FAILED: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Step 1: Merge baseline and custom plugins
+	if err := mergePlugins(); err != nil {
+		fmt.Fprintf(os.Stderr, "FAILED: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Step 2: Install plugins (clean output)
+	if err := installPlugins(); err != nil {
+		fmt.Fprintf(os.Stderr, "FAILED: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Step 3: Merge plugin files into storefront
+	fmt.Println("\n==> Merging plugin files into storefront")
+	if err := mergePluginFiles(); err != nil {
+		fmt.Fprintf(os.Stderr, "FAILED: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,552 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The pocketstore build runs the scripts in bin/ that assemble storefront/ as one pipeline, so
+// the Dockerfile, docker-entrypoint.sh and the deployment workflows all build the same way:
+//
+//	go run bin/pocketstore.go build                          run every step
+//	go run bin/pocketstore.go build --only custom,theme      run only these steps
+//	go run bin/pocketstore.go build --skip update,checks     run every step but these
+//	go run bin/pocketstore.go steps                          print the steps and what they need
+//
+// Steps run one at a time in dependency order. When a step fails, the steps needing it are
+// skipped, the others still run. Steps left out with --only or --skip do not block the steps
+// needing them; those use whatever the previous build left behind.
+//
+// Steps declaring inputs are cached: a step is skipped when the content of its inputs (and of
+// its script) and of its outputs is the same as at the end of the last build it succeeded in.
+// The fingerprints are kept in .plugins/build.json; --no-cache runs every step. The values of
+// POCKETSTORE_ENV, of the variables custom/pocketstore*.json references as ${VAR} and of a
+// step's Env count as inputs, taken from the environment or .env. Remote input like the
+// extensions feed of bin/plugins.go is not part of the fingerprint.
+
+// Step is one script of the build
+type Step struct {
+	Name   string
+	Script string
+	Args   []string
+	Needs  []string
+	About  string
+	// Inputs and Outputs are files, directories or globs. Steps without inputs always run.
+	Inputs  []string
+	Outputs []string
+	// Env are environment variables the step reads besides the ones the config references
+	Env []string
+	// Dir is the directory the script runs in, the repository root if empty
+	Dir string
+}
+
+// Result is the outcome of a step
+type Result struct {
+	Step     Step
+	Status   string // "ok", "cached", "failed", "skipped"
+	Reason   string
+	Duration time.Duration
+	// Blocked is set when the step failed or could not run, so the steps needing it do not run
+	Blocked bool
+}
+
+var (
+	only    = flag.String("only", "", "comma separated steps to run, all if empty")
+	skip    = flag.String("skip", "", "comma separated steps not to run")
+	dryRun  = flag.Bool("dry-run", false, "print the steps that would run without running them")
+	noCache = flag.Bool("no-cache", false, "run every step, even when its inputs did not change")
+
+	// buildCache stores the fingerprints of every cached step after the last build
+	buildCache = ".plugins/build.json"
+
+	// envPattern matches ${NAME} and ${NAME:-default} in custom/pocketstore*.json.
+	// Keep in sync with bin/custom.go.
+	envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+	// skipHash are directories never part of a fingerprint
+	skipHash = map[string]bool{".git": true, "node_modules": true, ".nuxt": true, ".output": true}
+
+	// steps is the build in declaration order, which is also the order of independent steps
+	steps = []Step{
+		{Name: "config", Script: "bin/config.go", Args: []string{"validate"}, About: "validate custom/pocketstore.json",
+			Inputs: []string{"custom/pocketstore*.json", ".env"}},
+		{Name: "update", Script: "bin/update.go", Needs: []string{"config"}, About: "update the baseline submodule"},
+		{Name: "plugins", Script: "bin/plugins.go", Needs: []string{"update"}, About: "install plugins and copy them into storefront/",
+			Inputs:  []string{"baseline/plugins.json", "custom", "storefront/plugins.json", ".env"},
+			Outputs: []string{"storefront", ".plugins/repos", ".plugins/installed.json", ".hooks"},
+			Env:     []string{"POCKETSTORE_DOWNLOAD_URL", "POCKETSTORE_EXTENSIONS_URL"}},
+		{Name: "schema", Script: "bin/schema.go", Needs: []string{"plugins"}, About: "merge schema.json of all layers into .data/",
+			Inputs:  []string{"baseline/schema.json", "custom/schema.json", "custom/pocketstore.json", ".plugins/repos/*/*/schema.json", ".plugins/repos/*/*/plugin.json"},
+			Outputs: []string{".data/schema.json"}},
+		{Name: "migrations", Script: "bin/migrations.go", Needs: []string{"plugins"}, About: "copy plugin migrations",
+			Inputs:  []string{".plugins/repos/*/*/migrations", ".plugins/installed.json"},
+			Outputs: []string{".migrations", ".plugins/migrations.json"}},
+		{Name: "custom", Script: "bin/custom.go", Needs: []string{"plugins"}, About: "copy custom/, resolve pocketstore.json, apply patches",
+			Inputs:  []string{"custom", "baseline", ".plugins/repos", ".env", "bin/patches.go"},
+			Outputs: []string{"storefront"}},
+		{Name: "theme", Script: "bin/theme.go", Needs: []string{"custom"}, About: "generate storefront/daisyui.css from the theme tokens",
+			Inputs:  []string{"storefront/app/pocketstore.json", "custom/pocketstore.json"},
+			Outputs: []string{"storefront/daisyui.css"}},
+		{Name: "translations", Script: "bin/translations.go", Needs: []string{"plugins", "custom"}, About: "merge translations of all layers",
+			Inputs:  []string{"baseline/translations", "custom/translations", "custom/pocketstore.json", ".plugins/repos/*/*/translations", ".plugins/repos/*/*/plugin.json"},
+			Outputs: []string{"storefront/i18n/locales"}},
+		{Name: "sitemap", Script: "bin/sitemap.go", Needs: []string{"custom", "translations"}, About: "generate the sitemap",
+			Dir: "storefront"},
+		{Name: "checks", Script: "bin/checks.go", Needs: []string{"custom", "translations"}, About: "run bin/checks/*.go",
+			Inputs:  []string{"bin/checks", "custom", ".hooks", ".plugins/repos", "storefront", ".secrets-allowlist"},
+			Outputs: []string{".plugins/audit"}},
+	}
+)
+
+// splitNames parses a comma separated list of step names
+func splitNames(list string) (map[string]bool, error) {
+	names := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := findStep(name); !ok {
+			return nil, fmt.Errorf("unknown step %q (available: %s)", name, strings.Join(stepNames(), ", "))
+		}
+		names[name] = true
+	}
+	return names, nil
+}
+
+func findStep(name string) (Step, bool) {
+	for _, s := range steps {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Step{}, false
+}
+
+func stepNames() []string {
+	names := make([]string, len(steps))
+	for i, s := range steps {
+		names[i] = s.Name
+	}
+	return names
+}
+
+// order returns the steps sorted so every step comes after the steps it needs. Among steps
+// that are ready at the same time the declaration order wins.
+func order() ([]Step, error) {
+	done := make(map[string]bool)
+	var sorted []Step
+	for len(sorted) < len(steps) {
+		progress := false
+		for _, s := range steps {
+			if done[s.Name] {
+				continue
+			}
+			ready := true
+			for _, need := range s.Needs {
+				if _, ok := findStep(need); !ok {
+					return nil, fmt.Errorf("step %s needs unknown step %s", s.Name, need)
+				}
+				ready = ready && done[need]
+			}
+			if ready {
+				done[s.Name] = true
+				sorted = append(sorted, s)
+				progress = true
+				break
+			}
+		}
+		if !progress {
+			return nil, fmt.Errorf("steps depend on each other in a cycle")
+		}
+	}
+	return sorted, nil
+}
+
+// scriptPath returns the path of the script of s relative to the repository root
+func scriptPath(s Step) string {
+	return filepath.Join(s.Dir, s.Script)
+}
+
+// command describes how s is run
+func command(s Step) string {
+	line := "go run " + strings.TrimSpace(s.Script+" "+strings.Join(s.Args, " "))
+	if s.Dir != "" {
+		line += " (in " + s.Dir + "/)"
+	}
+	return line
+}
+
+// runStep runs the script of a step with the output passed through
+func runStep(s Step) error {
+	if !fileExists(scriptPath(s)) {
+		return fmt.Errorf("%s not found", scriptPath(s))
+	}
+	cmd := exec.Command("go", append([]string{"run", s.Script}, s.Args...)...)
+	cmd.Dir = s.Dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// CacheEntry is the fingerprint of a step's inputs and outputs after the last build it succeeded in
+type CacheEntry struct {
+	Inputs  string    `json:"inputs"`
+	Outputs string    `json:"outputs"`
+	Built   time.Time `json:"built"`
+}
+
+// hasher fingerprints paths, remembering the hash of every path until forget is called
+type hasher struct {
+	paths map[string]string
+}
+
+func (h *hasher) forget() {
+	h.paths = make(map[string]string)
+}
+
+// hashPath returns the hash of a file, a symlink or every file below a directory
+func (h *hasher) hashPath(path string) (string, error) {
+	if sum, ok := h.paths[path]; ok {
+		return sum, nil
+	}
+	sum := sha256.New()
+	err := filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if skipHash[d.Name()] && p != path {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, _ := filepath.Rel(path, p)
+		fmt.Fprintf(sum, "%s\x00", filepath.ToSlash(rel))
+		// Links made with --link are hashed by their target, the content is an input of its own
+		if d.Type()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(p)
+			fmt.Fprintf(sum, "link %s\x00", target)
+			return err
+		}
+		file, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(sum, file)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	h.paths[path] = fmt.Sprintf("%x", sum.Sum(nil))
+	return h.paths[path], nil
+}
+
+// fingerprint hashes everything matching patterns; missing paths are part of it too
+func (h *hasher) fingerprint(patterns []string) (string, error) {
+	sum := sha256.New()
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return "", err
+		}
+		if len(matches) == 0 {
+			fmt.Fprintf(sum, "%s missing\n", pattern)
+		}
+		for _, match := range matches {
+			path, err := h.hashPath(match)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(sum, "%s %s\n", filepath.ToSlash(match), path)
+		}
+	}
+	return fmt.Sprintf("%x", sum.Sum(nil)), nil
+}
+
+// envFromDotEnv returns the environment variable key, falling back to the .env file so
+// scripts run on the host see the same values as docker compose.
+// Keep in sync with bin/custom.go.
+func envFromDotEnv(key string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	data, err := os.ReadFile(".env")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		name, value, found := strings.Cut(strings.TrimSpace(line), "=")
+		if !found || strings.TrimSpace(strings.TrimPrefix(name, "export ")) != key {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		return value
+	}
+	return ""
+}
+
+// configEnv returns the sorted names of the variables custom/pocketstore*.json references
+func configEnv() []string {
+	names := make(map[string]bool)
+	files, _ := filepath.Glob("custom/pocketstore*.json")
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		for _, m := range envPattern.FindAllStringSubmatch(string(data), -1) {
+			names[m[1]] = true
+		}
+	}
+	var sorted []string
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// entry fingerprints the current inputs and outputs of s. The script, its arguments and the
+// environment it reads count as inputs.
+func (h *hasher) entry(s Step) (CacheEntry, error) {
+	inputs, err := h.fingerprint(append([]string{scriptPath(s)}, s.Inputs...))
+	if err != nil {
+		return CacheEntry{}, err
+	}
+	outputs, err := h.fingerprint(s.Outputs)
+	if err != nil {
+		return CacheEntry{}, err
+	}
+	key := fmt.Sprintf("%s %s\n", inputs, strings.Join(s.Args, " "))
+	for _, name := range append(append([]string{"POCKETSTORE_ENV"}, configEnv()...), s.Env...) {
+		key += fmt.Sprintf("%s=%s\n", name, envFromDotEnv(name))
+	}
+	return CacheEntry{Inputs: fmt.Sprintf("%x", sha256.Sum256([]byte(key))), Outputs: outputs}, nil
+}
+
+// cached reports whether s may be skipped because nothing changed since it last succeeded
+func (h *hasher) cached(s Step, cache map[string]CacheEntry) bool {
+	previous, ok := cache[s.Name]
+	if *noCache || len(s.Inputs) == 0 || !ok {
+		return false
+	}
+	current, err := h.entry(s)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: cannot fingerprint %s, running it: %v\n", s.Name, err)
+		return false
+	}
+	return current.Inputs == previous.Inputs && current.Outputs == previous.Outputs
+}
+
+func readCache() map[string]CacheEntry {
+	cache := make(map[string]CacheEntry)
+	if data, err := os.ReadFile(buildCache); err == nil {
+		if err := json.Unmarshal(data, &cache); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: ignoring %s: %v\n", buildCache, err)
+			return make(map[string]CacheEntry)
+		}
+	}
+	return cache
+}
+
+// writeCache records the fingerprints of the steps that succeeded or were cached, as they are
+// at the end of the build, and forgets the steps that failed
+func writeCache(cache map[string]CacheEntry, summary []*Result) error {
+	h := &hasher{}
+	h.forget()
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, r := range summary {
+		switch {
+		case r.Status == "failed":
+			delete(cache, r.Step.Name)
+		case r.Status == "ok" && len(r.Step.Inputs) > 0:
+			entry, err := h.entry(r.Step)
+			if err != nil {
+				return err
+			}
+			entry.Built = now
+			cache[r.Step.Name] = entry
+		case r.Status == "cached":
+			entry, err := h.entry(r.Step)
+			if err != nil {
+				return err
+			}
+			// Built stays the time the step last ran
+			entry.Built = cache[r.Step.Name].Built
+			cache[r.Step.Name] = entry
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(buildCache), 0755); err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(buildCache, out, 0644)
+}
+
+// build runs the selected steps and prints a summary
+func build() error {
+	selected, err := splitNames(*only)
+	if err != nil {
+		return err
+	}
+	skipped, err := splitNames(*skip)
+	if err != nil {
+		return err
+	}
+	sorted, err := order()
+	if err != nil {
+		return err
+	}
+
+	var plan []Step
+	results := make(map[string]*Result)
+	var summary []*Result
+	for _, s := range sorted {
+		r := &Result{Step: s}
+		switch {
+		case len(selected) > 0 && !selected[s.Name]:
+			continue
+		case skipped[s.Name]:
+			r.Status, r.Reason = "skipped", "--skip"
+		default:
+			plan = append(plan, s)
+		}
+		results[s.Name] = r
+		summary = append(summary, r)
+	}
+
+	cache := readCache()
+	h := &hasher{}
+	h.forget()
+
+	if *dryRun {
+		for i, s := range plan {
+			note := ""
+			if h.cached(s, cache) {
+				note = "  (cached unless an earlier step changes its inputs)"
+			}
+			fmt.Printf("%2d. %-13s %s%s\n", i+1, s.Name, command(s), note)
+		}
+		return nil
+	}
+
+	start := time.Now()
+	var failed int
+	for i, s := range plan {
+		r := results[s.Name]
+		for _, need := range s.Needs {
+			if dep, ok := results[need]; ok && dep.Blocked {
+				r.Status, r.Reason, r.Blocked = "skipped", need+" did not succeed", true
+				break
+			}
+		}
+		if r.Status != "" {
+			continue
+		}
+		if h.cached(s, cache) {
+			r.Status, r.Reason = "cached", "inputs and outputs unchanged"
+			continue
+		}
+
+		fmt.Printf("\n=== [%d/%d] %s: %s ===\n", i+1, len(plan), s.Name, command(s))
+		stepStart := time.Now()
+		err := runStep(s)
+		r.Duration = time.Since(stepStart)
+		// The step may have changed any file
+		h.forget()
+		if err != nil {
+			r.Status, r.Reason, r.Blocked = "failed", err.Error(), true
+			failed++
+			continue
+		}
+		r.Status = "ok"
+	}
+
+	fmt.Printf("\nBuild summary:\n")
+	for _, r := range summary {
+		marker := map[string]string{"ok": "✓", "cached": "=", "failed": "✗", "skipped": "-"}[r.Status]
+		line := fmt.Sprintf("  %s %-13s", marker, r.Step.Name)
+		if r.Status == "ok" || r.Status == "failed" {
+			line += fmt.Sprintf(" %7s", r.Duration.Round(100*time.Millisecond))
+		} else {
+			line += strings.Repeat(" ", 8)
+		}
+		if r.Reason != "" {
+			line += "  " + r.Status + ": " + r.Reason
+		}
+		fmt.Println(line)
+	}
+	fmt.Printf("Total %s\n", time.Since(start).Round(100*time.Millisecond))
+
+	if err := writeCache(cache, summary); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: cannot write %s: %v\n", buildCache, err)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d step(s) failed", failed)
+	}
+	return nil
+}
+
+// printSteps prints the steps in the order they run
+func printSteps() error {
+	sorted, err := order()
+	if err != nil {
+		return err
+	}
+	for _, s := range sorted {
+		needs := "-"
+		if len(s.Needs) > 0 {
+			needs = strings.Join(s.Needs, ", ")
+		}
+		fmt.Printf("  %-13s needs %-22s %s\n", s.Name, needs, s.About)
+	}
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: go run bin/pocketstore.go build [--only a,b] [--skip c] [--dry-run] | steps")
+		os.Exit(2)
+	}
+	command := os.Args[1]
+	flag.CommandLine.Parse(os.Args[2:])
+
+	var err error
+	switch command {
+	case "build":
+		err = build()
+	case "steps":
+		err = printSteps()
+	default:
+		err = fmt.Errorf("unknown command %q (available: build, steps)", command)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "FAILED: %v\n", err)
+		os.Exit(1)
+	}
+}
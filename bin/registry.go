@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Registry serves plugin zips from a directory laid out as <root>/<vendor>/<name>/<version>.zip,
+// which is what `go run bin/plugins.go pack` writes, using the URL scheme of
+// download.pocketstore.io:
+//
+//	GET /d/plugins/{vendor}/{name}/{version}.zip  plugin zip ("latest.zip" is the highest version)
+//	GET /d/plugins/{vendor}/{name}/versions.json  available versions, highest first
+//	GET /extensions.json                          <root>/extensions.json
+type Registry struct {
+	Root string
+}
+
+// versions returns the versions available for vendor/name, highest first
+func (r *Registry) versions(vendor, name string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(r.Root, vendor, name, "*.zip"))
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, m := range matches {
+		v := strings.TrimSuffix(filepath.Base(m), ".zip")
+		if v == "latest" {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return compareVersions(versions[i], versions[j]) > 0
+	})
+	return versions, nil
+}
+
+// compareVersions compares dotted versions like "0.0.1.2" component by component,
+// numerically where possible
+func compareVersions(a, b string) int {
+	pa := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	pb := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y string
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		nx, errX := strconv.Atoi(x)
+		ny, errY := strconv.Atoi(y)
+		switch {
+		case errX == nil && errY == nil:
+			if nx != ny {
+				if nx < ny {
+					return -1
+				}
+				return 1
+			}
+		case x != y:
+			return strings.Compare(x, y)
+		}
+	}
+	return 0
+}
+
+// isSafeSegment rejects path segments that could escape the registry root
+func isSafeSegment(s string) bool {
+	return s != "" && s != "." && s != ".." && !strings.ContainsAny(s, `/\`)
+}
+
+func (r *Registry) handlePlugin(w http.ResponseWriter, req *http.Request) {
+	vendor, name, file := req.PathValue("vendor"), req.PathValue("name"), req.PathValue("file")
+	if !isSafeSegment(vendor) || !isSafeSegment(name) || !isSafeSegment(file) {
+		http.NotFound(w, req)
+		return
+	}
+
+	if file == "versions.json" {
+		versions, err := r.versions(vendor, name)
+		if err != nil || len(versions) == 0 {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"vendor":   vendor,
+			"name":     name,
+			"latest":   versions[0],
+			"versions": versions,
+		})
+		return
+	}
+
+	if !strings.HasSuffix(file, ".zip") {
+		http.NotFound(w, req)
+		return
+	}
+	path := filepath.Join(r.Root, vendor, name, file)
+
+	// latest.zip resolves to the highest version unless an explicit latest.zip exists
+	if file == "latest.zip" && !fileExists(path) {
+		versions, err := r.versions(vendor, name)
+		if err != nil || len(versions) == 0 {
+			http.NotFound(w, req)
+			return
+		}
+		path = filepath.Join(r.Root, vendor, name, versions[0]+".zip")
+	}
+	if !fileExists(path) {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	http.ServeFile(w, req, path)
+}
+
+func (r *Registry) handleExtensions(w http.ResponseWriter, req *http.Request) {
+	path := filepath.Join(r.Root, "extensions.json")
+	if !fileExists(path) {
+		// Same shape as plugins.pocketstore.io/extensions.json, just empty
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(w, `{"store":{}}`)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	http.ServeFile(w, req, path)
+}
+
+// fileExists reports whether path exists and is a regular file
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// logRequests prints one line per request
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Printf("%s %s\n", req.Method, req.URL.Path)
+		next.ServeHTTP(w, req)
+	})
+}
+
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8070", "listen address")
+	root := fs.String("root", ".plugins/dist", "directory containing <vendor>/<name>/<version>.zip and extensions.json")
+	fs.Parse(args)
+
+	info, err := os.Stat(*root)
+	if err != nil || !info.IsDir() {
+		return fmt.Errorf("registry root %s is not a directory", *root)
+	}
+
+	registry := &Registry{Root: *root}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /d/plugins/{vendor}/{name}/{file}", registry.handlePlugin)
+	mux.HandleFunc("GET /extensions.json", registry.handleExtensions)
+
+	fmt.Printf("Serving plugins from %s on %s\n", *root, *addr)
+	return http.ListenAndServe(*addr, logRequests(mux))
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "serve" {
+		fmt.Fprintln(os.Stderr, "usage: go run bin/registry.go serve [--addr :8070] [--root .plugins/dist]")
+		os.Exit(2)
+	}
+	if err := serve(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "FAILED: %v\n", err)
+		os.Exit(1)
+	}
+}
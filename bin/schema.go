@@ -6,39 +6,85 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 )
 
-func main() {
-	// Pattern: .plugins/repos/*/*/schema.json
-	pattern := ".plugins/repos/*/*/schema.json"
+type PluginJson struct {
+	Prio int `json:"prio"`
+}
 
-	matches, err := filepath.Glob(pattern)
-	if err != nil {
-		panic(err)
-	}
+// schemaSource is a schema.json of one layer
+type schemaSource struct {
+	File string
+	Prio int
+}
 
-	if len(matches) == 0 {
-		fmt.Println("No schema.json files found.")
-		return
+func main() {
+	// Collect schema.json files in layer precedence order, lowest first (see bin/layers.go).
+	// A collection defined by several layers is taken from the highest one.
+	var sources []schemaSource
+	for _, layer := range readLayers() {
+		switch layer {
+		case "baseline":
+			sources = append(sources, schemaSource{File: filepath.Join("baseline", "schema.json")})
+		case "custom":
+			sources = append(sources, schemaSource{File: filepath.Join("custom", "schema.json")})
+		case "plugins":
+			// Pattern: .plugins/repos/*/*/schema.json
+			matches, err := filepath.Glob(".plugins/repos/*/*/schema.json")
+			if err != nil {
+				panic(err)
+			}
+			var plugins []schemaSource
+			for _, file := range matches {
+				plugins = append(plugins, schemaSource{File: file, Prio: readPrio(filepath.Join(filepath.Dir(file), "plugin.json"))})
+			}
+			// Highest prio first so the lowest prio plugin wins, like files and translations
+			sort.SliceStable(plugins, func(i, j int) bool {
+				return plugins[i].Prio > plugins[j].Prio
+			})
+			sources = append(sources, plugins...)
+		}
 	}
 
 	merged := []map[string]interface{}{}
+	index := make(map[string]int) // collection name -> position in merged
+	found := 0
 
-	for _, file := range matches {
-		data, err := ioutil.ReadFile(file)
+	for _, source := range sources {
+		data, err := ioutil.ReadFile(source.File)
 		if err != nil {
-			fmt.Printf("Error reading %s: %v\n", file, err)
+			if !os.IsNotExist(err) {
+				fmt.Printf("Error reading %s: %v\n", source.File, err)
+			}
 			continue
 		}
+		found++
 
 		var arr []map[string]interface{}
 		if err := json.Unmarshal(data, &arr); err != nil {
-			fmt.Printf("Error parsing JSON in %s: %v\n", file, err)
+			fmt.Printf("Error parsing JSON in %s: %v\n", source.File, err)
 			continue
 		}
 
-		merged = append(merged, arr...)
-		fmt.Printf("Merged %d entries from %s\n", len(arr), file)
+		for _, collection := range arr {
+			key := collectionKey(collection)
+			if i, ok := index[key]; ok && key != "" {
+				fmt.Printf("  %s overrides collection %s\n", source.File, key)
+				merged[i] = collection
+				continue
+			}
+			if key != "" {
+				index[key] = len(merged)
+			}
+			merged = append(merged, collection)
+		}
+		fmt.Printf("Merged %d entries from %s\n", len(arr), source.File)
+	}
+
+	if found == 0 {
+		fmt.Println("No schema.json files found.")
+		return
 	}
 
 	// Ensure output directory exists
@@ -62,3 +108,58 @@ func main() {
 
 	fmt.Printf("Merged schema written to %s (%d total objects)\n", outputFile, len(merged))
 }
+
+// collectionKey identifies a collection by its name, falling back to its id
+func collectionKey(collection map[string]interface{}) string {
+	if name, ok := collection["name"].(string); ok && name != "" {
+		return name
+	}
+	id, _ := collection["id"].(string)
+	return id
+}
+
+// readLayers returns the layer precedence configured in custom/pocketstore.json ("layers"),
+// lowest first. See bin/layers.go.
+func readLayers() []string {
+	defaultLayers := []string{"baseline", "plugins", "custom"}
+	var config struct {
+		Layers []string `json:"layers"`
+	}
+	data, err := os.ReadFile(filepath.Join("custom", "pocketstore.json"))
+	if err != nil || json.Unmarshal(data, &config) != nil || len(config.Layers) == 0 {
+		return defaultLayers
+	}
+	if !validLayers(config.Layers) {
+		if !layersWarned {
+			fmt.Fprintf(os.Stderr, "Warning: invalid \"layers\" %v in custom/pocketstore.json, using %v\n", config.Layers, defaultLayers)
+			layersWarned = true
+		}
+		return defaultLayers
+	}
+	return config.Layers
+}
+
+// layersWarned makes readLayers warn about invalid "layers" only once
+var layersWarned bool
+
+// validLayers reports whether layers names baseline, plugins and custom exactly once with
+// baseline first. Keep in sync with bin/layers.go.
+func validLayers(layers []string) bool {
+	if len(layers) != 3 || layers[0] != "baseline" {
+		return false
+	}
+	return (layers[1] == "plugins" && layers[2] == "custom") || (layers[1] == "custom" && layers[2] == "plugins")
+}
+
+// readPrio reads the priority from a plugin.json file
+func readPrio(jsonPath string) int {
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		return 0
+	}
+	var pj PluginJson
+	if err := json.Unmarshal(data, &pj); err != nil {
+		return 0
+	}
+	return pj.Prio
+}
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Generates the daisyUI theme storefront/daisyui.css from "theme" in pocketstore.json:
+//
+//	go run bin/theme.go generate     write storefront/daisyui.css (runs after bin/custom.go)
+//	go run bin/theme.go check        only report WCAG contrast of paired colours
+//
+//	"theme": {
+//	  "name": "pocketstore",
+//	  "radius": { "selector": "1rem", "field": "0.25rem", "box": "0.5rem" },
+//	  "fonts": { "sans": "Inter, sans-serif" },
+//	  "light": { "primary": "#4f46e5", "primary-content": "#ffffff", "base-100": "#ffffff" },
+//	  "dark": { "base-100": "#1d232a", "base-content": "#ecf9ff" }
+//	}
+//
+// Colours are #rgb, #rrggbb, rgb(…) or oklch(…). Missing colours fall back to daisyUI's defaults,
+// missing *-content colours are derived for contrast and dark inherits what it does not set from
+// light. Without "theme" nothing is generated and bin/custom.go copies custom/daisyui.css.
+
+// Theme holds the theme tokens. Keep in sync with bin/config.go.
+type Theme struct {
+	Name   string            `json:"name,omitempty"`
+	Radius map[string]string `json:"radius,omitempty"` // selector, field, box
+	Fonts  map[string]string `json:"fonts,omitempty"`  // sans, serif, mono
+	Light  map[string]string `json:"light,omitempty"`
+	Dark   map[string]string `json:"dark,omitempty"`
+}
+
+// BuildRecord is the hash of a storefront file as the build wrote it
+type BuildRecord struct {
+	SHA256 string `json:"sha256"`
+	Layer  string `json:"layer"`
+}
+
+// rgb is a colour in linear sRGB, each channel 0..1
+type rgb struct{ R, G, B float64 }
+
+var (
+	configFiles = []string{"storefront/app/pocketstore.json", "custom/pocketstore.json"}
+	themeTarget = "storefront/daisyui.css"
+	buildLedger = ".plugins/storefront.json"
+
+	minContrast = flag.Float64("min-contrast", 4.5, "minimum WCAG contrast ratio between a colour and its -content colour (0 disables)")
+
+	// colorNames are the daisyUI colours; every one except base-* has a -content pair
+	colorNames = []string{"primary", "secondary", "accent", "neutral", "info", "success", "warning", "error"}
+	baseNames  = []string{"base-100", "base-200", "base-300"}
+
+	// defaults approximate daisyUI's own light and dark themes
+	defaults = map[string]map[string]string{
+		"light": {
+			"base-100": "#ffffff", "base-200": "#f8f8f8", "base-300": "#eeeeee", "base-content": "#18181b",
+			"primary": "#605dff", "secondary": "#f43098", "accent": "#00d3bb", "neutral": "#09090b",
+			"info": "#00bafe", "success": "#00d390", "warning": "#fcb700", "error": "#ff627d",
+		},
+		"dark": {
+			"base-100": "#1d232a", "base-200": "#191e24", "base-300": "#15191e", "base-content": "#ecf9ff",
+		},
+	}
+
+	radiusNames = []string{"selector", "field", "box"}
+	fontNames   = []string{"sans", "serif", "mono"}
+
+	hexPattern    = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+	funcPattern   = regexp.MustCompile(`^(rgb|oklch)\(\s*([^)]*)\)$`)
+	lengthPattern = regexp.MustCompile(`^(0|[0-9]*\.?[0-9]+(rem|px|em))$`)
+	namePattern   = regexp.MustCompile(`^[a-z0-9-]+$`)
+)
+
+// readTheme returns the theme of the resolved config bin/custom.go wrote, or of custom/pocketstore.json
+func readTheme() (*Theme, string, error) {
+	for _, path := range configFiles {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, path, err
+		}
+		var config struct {
+			Theme *Theme `json:"theme"`
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, path, fmt.Errorf("failed to parse %s: %v", path, err)
+		}
+		return config.Theme, path, nil
+	}
+	return nil, "", nil
+}
+
+// linearize converts an sRGB channel 0..1 to linear light
+func linearize(c float64) float64 {
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+// parseNumber parses a number, a percentage (scaled to 0..1 times scale) or a hue in deg
+func parseNumber(s string, scale float64) (float64, error) {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "deg")
+	if strings.HasSuffix(s, "%") {
+		v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		return v / 100 * scale, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// parseColor parses a CSS colour into linear sRGB
+func parseColor(s string) (rgb, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if hexPattern.MatchString(s) {
+		h := s[1:]
+		if len(h) == 3 {
+			h = string([]byte{h[0], h[0], h[1], h[1], h[2], h[2]})
+		}
+		v, _ := strconv.ParseUint(h, 16, 32)
+		return rgb{
+			linearize(float64(v>>16&0xff) / 255),
+			linearize(float64(v>>8&0xff) / 255),
+			linearize(float64(v&0xff) / 255),
+		}, nil
+	}
+
+	m := funcPattern.FindStringSubmatch(s)
+	if m == nil {
+		return rgb{}, fmt.Errorf("%q is not a colour (use #rrggbb, rgb(…) or oklch(…))", s)
+	}
+	args := strings.Fields(strings.NewReplacer(",", " ", "/", " ").Replace(m[2]))
+	if len(args) < 3 {
+		return rgb{}, fmt.Errorf("%q needs three components", s)
+	}
+	var v [3]float64
+	for i := 0; i < 3; i++ {
+		scale := 255.0
+		if m[1] == "oklch" {
+			scale = []float64{1, 0.4, 360}[i]
+		}
+		n, err := parseNumber(args[i], scale)
+		if err != nil {
+			return rgb{}, fmt.Errorf("%q has an invalid component %q", s, args[i])
+		}
+		v[i] = n
+	}
+
+	if m[1] == "rgb" {
+		return rgb{linearize(v[0] / 255), linearize(v[1] / 255), linearize(v[2] / 255)}, nil
+	}
+
+	// oklch -> oklab -> linear sRGB
+	l, c, h := v[0], v[1], v[2]*math.Pi/180
+	a, b := c*math.Cos(h), c*math.Sin(h)
+	l_ := math.Pow(l+0.3963377774*a+0.2158037573*b, 3)
+	m_ := math.Pow(l-0.1055613458*a-0.0638541728*b, 3)
+	s_ := math.Pow(l-0.0894841775*a-1.2914855480*b, 3)
+	clamp := func(x float64) float64 { return math.Max(0, math.Min(1, x)) }
+	return rgb{
+		clamp(4.0767416621*l_ - 3.3077115913*m_ + 0.2309699292*s_),
+		clamp(-1.2684380046*l_ + 2.6097574011*m_ - 0.3413193965*s_),
+		clamp(-0.0041960863*l_ - 0.7034186147*m_ + 1.7076147010*s_),
+	}, nil
+}
+
+// luminance is the WCAG relative luminance
+func (c rgb) luminance() float64 {
+	return 0.2126*c.R + 0.7152*c.G + 0.0722*c.B
+}
+
+// contrast is the WCAG contrast ratio of two colours, 1..21
+func contrast(a, b rgb) float64 {
+	la, lb := a.luminance(), b.luminance()
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// contentFor picks black or white text, whichever contrasts more with background
+func contentFor(background rgb) string {
+	if contrast(background, rgb{0, 0, 0}) >= contrast(background, rgb{1, 1, 1}) {
+		return "#000000"
+	}
+	return "#ffffff"
+}
+
+// resolveColors fills in defaults and derived -content colours for a variant. inherited are
+// the colours set for light, whose brand colours dark reuses unless it sets its own.
+func resolveColors(variant string, own, inherited map[string]string) (map[string]string, []string) {
+	colors := make(map[string]string)
+	for name, value := range defaults["light"] {
+		colors[name] = value
+	}
+	for name, value := range defaults[variant] {
+		colors[name] = value
+	}
+	explicit := make(map[string]bool)
+	for name, value := range inherited {
+		if !strings.HasPrefix(name, "base-") {
+			colors[name] = value
+			explicit[name] = true
+		}
+	}
+
+	known := map[string]bool{"base-content": true}
+	for _, name := range baseNames {
+		known[name] = true
+	}
+	for _, name := range colorNames {
+		known[name] = true
+		known[name+"-content"] = true
+	}
+	var problems []string
+	for _, name := range sortedKeys(own) {
+		if !known[name] {
+			problems = append(problems, fmt.Sprintf("theme.%s.%s: unknown colour", variant, name))
+			continue
+		}
+		if _, err := parseColor(own[name]); err != nil {
+			problems = append(problems, fmt.Sprintf("theme.%s.%s: %v", variant, name, err))
+			continue
+		}
+		colors[name] = own[name]
+		explicit[name] = true
+	}
+
+	for _, name := range colorNames {
+		// An inherited -content colour does not fit a colour this variant changes
+		if _, ok := own[name]; ok {
+			if _, ok := own[name+"-content"]; !ok {
+				explicit[name+"-content"] = false
+			}
+		}
+		if explicit[name+"-content"] {
+			continue
+		}
+		if bg, err := parseColor(colors[name]); err == nil {
+			colors[name+"-content"] = contentFor(bg)
+		}
+	}
+	return colors, problems
+}
+
+// checkContrast reports colour pairs below min
+func checkContrast(variant string, colors map[string]string, min float64) (lines []string, failed int) {
+	type pair struct{ bg, fg string }
+	var pairs []pair
+	for _, name := range baseNames {
+		pairs = append(pairs, pair{name, "base-content"})
+	}
+	for _, name := range colorNames {
+		pairs = append(pairs, pair{name, name + "-content"})
+	}
+	for _, p := range pairs {
+		bg, err1 := parseColor(colors[p.bg])
+		fg, err2 := parseColor(colors[p.fg])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		ratio := contrast(bg, fg)
+		mark := "✓"
+		if ratio < min {
+			mark = "✗"
+			failed++
+		}
+		lines = append(lines, fmt.Sprintf("  %s %-5s %-9s / %-17s %5.2f:1", mark, variant, p.bg, p.fg, ratio))
+	}
+	return lines, failed
+}
+
+// themeCSS renders one daisyUI theme
+func themeCSS(t *Theme, name, scheme string, isDefault, prefersDark bool, colors map[string]string) string {
+	var b strings.Builder
+	b.WriteString("@plugin \"daisyui/theme\" {\n")
+	fmt.Fprintf(&b, "  name: %q;\n", name)
+	fmt.Fprintf(&b, "  default: %t;\n", isDefault)
+	fmt.Fprintf(&b, "  prefersdark: %t;\n", prefersDark)
+	fmt.Fprintf(&b, "  color-scheme: %s;\n", scheme)
+
+	names := append([]string{}, baseNames...)
+	names = append(names, "base-content")
+	for _, n := range colorNames {
+		names = append(names, n, n+"-content")
+	}
+	for _, n := range names {
+		fmt.Fprintf(&b, "  --color-%s: %s;\n", n, colors[n])
+	}
+	for _, n := range radiusNames {
+		if v, ok := t.Radius[n]; ok {
+			fmt.Fprintf(&b, "  --radius-%s: %s;\n", n, v)
+		}
+	}
+	for _, n := range fontNames {
+		if v, ok := t.Fonts[n]; ok {
+			fmt.Fprintf(&b, "  --font-%s: %s;\n", n, v)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// validateTheme checks everything except colours
+func validateTheme(t *Theme) []string {
+	var problems []string
+	if t.Name != "" && !namePattern.MatchString(t.Name) {
+		problems = append(problems, fmt.Sprintf("theme.name: %q may only contain a-z, 0-9 and -", t.Name))
+	}
+	for _, key := range sortedKeys(t.Radius) {
+		if !contains(radiusNames, key) {
+			problems = append(problems, fmt.Sprintf("theme.radius.%s: unknown radius (use %s)", key, strings.Join(radiusNames, ", ")))
+		} else if !lengthPattern.MatchString(t.Radius[key]) {
+			problems = append(problems, fmt.Sprintf("theme.radius.%s: %q is not a length like 0.5rem", key, t.Radius[key]))
+		}
+	}
+	for _, key := range sortedKeys(t.Fonts) {
+		if !contains(fontNames, key) {
+			problems = append(problems, fmt.Sprintf("theme.fonts.%s: unknown font (use %s)", key, strings.Join(fontNames, ", ")))
+		} else if strings.ContainsAny(t.Fonts[key], ";{}") {
+			problems = append(problems, fmt.Sprintf("theme.fonts.%s: %q is not a font family list", key, t.Fonts[key]))
+		}
+	}
+	return problems
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// recordBuild stores the hashes of the storefront files just written (path -> layer) in the
+// build ledger, which `go run bin/drift.go` compares the storefront against
+func recordBuild(files map[string]string) error {
+	ledger := make(map[string]BuildRecord)
+	if data, err := os.ReadFile(buildLedger); err == nil {
+		if err := json.Unmarshal(data, &ledger); err != nil {
+			return fmt.Errorf("error parsing %s: %v", buildLedger, err)
+		}
+	}
+	for path, layer := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		ledger[filepath.ToSlash(path)] = BuildRecord{SHA256: fmt.Sprintf("%x", sha256.Sum256(data)), Layer: layer}
+	}
+	if err := os.MkdirAll(filepath.Dir(buildLedger), 0755); err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(ledger, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(buildLedger, out, 0644)
+}
+
+func run(write bool) error {
+	theme, source, err := readTheme()
+	if err != nil {
+		return err
+	}
+	if theme == nil {
+		fmt.Println("No \"theme\" in pocketstore.json, skipping")
+		return nil
+	}
+
+	problems := validateTheme(theme)
+	light, p := resolveColors("light", theme.Light, nil)
+	problems = append(problems, p...)
+	dark, p := resolveColors("dark", theme.Dark, theme.Light)
+	problems = append(problems, p...)
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		for _, problem := range problems {
+			fmt.Printf("  ✗ %s\n", problem)
+		}
+		return fmt.Errorf("invalid theme in %s", source)
+	}
+
+	fmt.Printf("Contrast of %s (minimum %.1f:1):\n", source, *minContrast)
+	var failed int
+	for _, variant := range []struct {
+		name   string
+		colors map[string]string
+	}{{"light", light}, {"dark", dark}} {
+		lines, n := checkContrast(variant.name, variant.colors, *minContrast)
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+		failed += n
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d colour pair(s) below %.1f:1, adjust the theme or pass --min-contrast", failed, *minContrast)
+	}
+	if !write {
+		return nil
+	}
+
+	name := theme.Name
+	if name == "" {
+		name = "pocketstore"
+	}
+	css := fmt.Sprintf("/* Generated by `go run bin/theme.go` from \"theme\" in %s, do not edit. */\n", source) +
+		themeCSS(theme, name, "light", true, false, light) + "\n" +
+		themeCSS(theme, name+"-dark", "dark", false, true, dark)
+	if err := os.MkdirAll(filepath.Dir(themeTarget), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(themeTarget, []byte(css), 0644); err != nil {
+		return err
+	}
+	if err := recordBuild(map[string]string{themeTarget: "theme"}); err != nil {
+		return fmt.Errorf("failed to record build: %v", err)
+	}
+	fmt.Printf("✓ Theme %s and %s-dark written to %s\n", name, name, themeTarget)
+	return nil
+}
+
+func main() {
+	command := "generate"
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	flag.CommandLine.Parse(args)
+
+	var err error
+	switch command {
+	case "generate":
+		err = run(true)
+	case "check":
+		err = run(false)
+	default:
+		err = fmt.Errorf("unknown command %q (available: generate, check)", command)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "FAILED: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -24,6 +25,7 @@ var (
 	baselineRoot   = "baseline"
 	customRoot     = "custom" // 👈 new: custom translations root
 	storefrontRoot = "storefront"
+	buildLedger    = ".plugins/storefront.json" // hashes of storefront files the build wrote, see bin/drift.go
 )
 
 func main() {
@@ -44,9 +46,9 @@ func main() {
 		}
 	}
 
-	// Get plugins sorted by priority (descending)
+	// Get plugins sorted by priority (descending, so the lowest prio is merged last and wins)
 	plugins := getPlugins()
-	sort.Slice(plugins, func(i, j int) bool {
+	sort.SliceStable(plugins, func(i, j int) bool {
 		return plugins[i].Prio > plugins[j].Prio
 	})
 
@@ -55,36 +57,48 @@ func main() {
 		fmt.Printf("Processing language: %s\n", langCode)
 		merged := make(map[string]interface{})
 
-		// 1️⃣ Start with baseline
-		baselineFile := filepath.Join(baselineTranslationsDir, langCode+".json")
-		if err := mergeTranslationFile(baselineFile, merged); err != nil {
-			fmt.Printf("  Error loading baseline: %v\n", err)
-			continue
-		}
-
-		// 2️⃣ Merge custom overrides right after baseline
-		customFile := filepath.Join(customRoot, "translations", langCode+".json")
-		if exists(customFile) {
-			fmt.Printf("  Merging custom translations: %s\n", customFile)
-			if err := mergeTranslationFile(customFile, merged); err != nil {
-				fmt.Printf("    Error merging custom file: %v\n", err)
-			}
-		}
+		// Merge the layers in precedence order, lowest first (see bin/layers.go)
+		failed := false
+		for _, layer := range readLayers() {
+			switch layer {
+			case "baseline":
+				// Baseline is required
+				baselineFile := filepath.Join(baselineTranslationsDir, langCode+".json")
+				if err := mergeTranslationFile(baselineFile, merged); err != nil {
+					fmt.Printf("  Error loading baseline: %v\n", err)
+					failed = true
+				}
 
-		// 3️⃣ Override with plugin translations (highest priority first)
-		for _, plugin := range plugins {
-			pluginTransFile := filepath.Join(plugin.BasePath, "translations", langCode+".json")
-			if exists(pluginTransFile) {
-				id := plugin.Name
-				if plugin.Vendor != "" {
-					id = plugin.Vendor + "/" + plugin.Name
+			case "custom":
+				// Custom overrides
+				customFile := filepath.Join(customRoot, "translations", langCode+".json")
+				if exists(customFile) {
+					fmt.Printf("  Merging custom translations: %s\n", customFile)
+					if err := mergeTranslationFile(customFile, merged); err != nil {
+						fmt.Printf("    Error merging custom file: %v\n", err)
+					}
 				}
-				fmt.Printf("  Merging from plugin %s (prio: %d)\n", id, plugin.Prio)
-				if err := mergeTranslationFile(pluginTransFile, merged); err != nil {
-					fmt.Printf("    Error merging: %v\n", err)
+
+			case "plugins":
+				// Plugin translations (lowest prio last)
+				for _, plugin := range plugins {
+					pluginTransFile := filepath.Join(plugin.BasePath, "translations", langCode+".json")
+					if exists(pluginTransFile) {
+						id := plugin.Name
+						if plugin.Vendor != "" {
+							id = plugin.Vendor + "/" + plugin.Name
+						}
+						fmt.Printf("  Merging from plugin %s (prio: %d)\n", id, plugin.Prio)
+						if err := mergeTranslationFile(pluginTransFile, merged); err != nil {
+							fmt.Printf("    Error merging: %v\n", err)
+						}
+					}
 				}
 			}
 		}
+		if failed {
+			continue
+		}
 
 		// 4️⃣ Write merged result to storefront/i18n/locales/{langcode}.json
 		outputDir := filepath.Join(storefrontRoot, "i18n", "locales")
@@ -99,10 +113,79 @@ func main() {
 			continue
 		}
 
+		if err := recordBuild(map[string]string{outputFile: "translations"}); err != nil {
+			fmt.Printf("  Error recording build: %v\n", err)
+		}
+
 		fmt.Printf("  Successfully generated %s\n", outputFile)
 	}
 }
 
+// BuildRecord is the hash of a storefront file as the build wrote it
+type BuildRecord struct {
+	SHA256 string `json:"sha256"`
+	Layer  string `json:"layer"`
+}
+
+// recordBuild stores the hashes of the storefront files just written (path -> layer) in the
+// build ledger, which `go run bin/drift.go` compares the storefront against
+func recordBuild(files map[string]string) error {
+	ledger := make(map[string]BuildRecord)
+	if data, err := os.ReadFile(buildLedger); err == nil {
+		if err := json.Unmarshal(data, &ledger); err != nil {
+			return fmt.Errorf("error parsing %s: %v", buildLedger, err)
+		}
+	}
+	for path, layer := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		ledger[filepath.ToSlash(path)] = BuildRecord{SHA256: fmt.Sprintf("%x", sha256.Sum256(data)), Layer: layer}
+	}
+	if err := os.MkdirAll(filepath.Dir(buildLedger), 0755); err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(ledger, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(buildLedger, out, 0644)
+}
+
+// readLayers returns the layer precedence configured in custom/pocketstore.json ("layers"),
+// lowest first. See bin/layers.go.
+func readLayers() []string {
+	defaultLayers := []string{"baseline", "plugins", "custom"}
+	var config struct {
+		Layers []string `json:"layers"`
+	}
+	data, err := os.ReadFile(filepath.Join(customRoot, "pocketstore.json"))
+	if err != nil || json.Unmarshal(data, &config) != nil || len(config.Layers) == 0 {
+		return defaultLayers
+	}
+	if !validLayers(config.Layers) {
+		if !layersWarned {
+			fmt.Fprintf(os.Stderr, "Warning: invalid \"layers\" %v in custom/pocketstore.json, using %v\n", config.Layers, defaultLayers)
+			layersWarned = true
+		}
+		return defaultLayers
+	}
+	return config.Layers
+}
+
+// layersWarned makes readLayers warn about invalid "layers" only once
+var layersWarned bool
+
+// validLayers reports whether layers names baseline, plugins and custom exactly once with
+// baseline first. Keep in sync with bin/layers.go.
+func validLayers(layers []string) bool {
+	if len(layers) != 3 || layers[0] != "baseline" {
+		return false
+	}
+	return (layers[1] == "plugins" && layers[2] == "custom") || (layers[1] == "custom" && layers[2] == "plugins")
+}
+
 func getPlugins() []Plugin {
 	var plugins []Plugin
 
@@ -121,6 +204,7 @@ func getPlugins() []Plugin {
 		// Check for legacy single-level layout
 		pluginJsonPath := filepath.Join(vendorPath, "plugin.json")
 		if exists(pluginJsonPath) {
+			fmt.Printf("Warning: %s uses the legacy layout, run `go run bin/plugins.go migrate-layout`\n", vendorPath)
 			prio := readPrio(pluginJsonPath)
 			plugins = append(plugins, Plugin{
 				Vendor:   "",
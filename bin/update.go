@@ -20,6 +20,12 @@ func runCommand(command string, args []string) error {
 }
 
 func main() {
+	// Record the baseline every override is based on before it moves, so the report below
+	// can show what changed upstream. The baseline is updated regardless.
+	if err := runCommand("go", []string{"run", "bin/layers.go", "overrides", "-q"}); err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: could not record overrides, the report below may miss upstream changes:", err)
+	}
+
 	// 1. Run git submodule update --init --recursive
 	err := runCommand("git", []string{"submodule", "update", "--init", "--recursive"})
 	if err != nil {
@@ -44,4 +50,10 @@ func main() {
 	}
 
 	fmt.Println("Submodules updated successfully.")
+
+	// 4. Report overrides whose baseline original changed with this update
+	fmt.Println()
+	if err := runCommand("go", []string{"run", "bin/layers.go", "overrides"}); err != nil {
+		fmt.Println("Some overrides need the upstream changes ported, see go run bin/layers.go overrides --diff")
+	}
 }